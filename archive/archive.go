@@ -0,0 +1,205 @@
+// Package archive exports a team to a portable zip of NDJSON files and
+// imports such an archive as a new team.
+//
+// An archive holds manifest.json and one NDJSON file per kind of record:
+// team, members, projects, sprints, tasks, templates, messages and activity,
+// each line being the record as the API returns it. users.ndjson lists the
+// users the records refer to, so they can be matched by email on import.
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+)
+
+const (
+	Format  = "collab-team-archive"
+	Version = 1
+)
+
+var ErrTeamNotFound = errors.New("Team not found")
+
+// Manifest describes an archive.
+type Manifest struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	TeamID     string         `json:"teamId"`
+	TeamName   string         `json:"teamName"`
+	Counts     map[string]int `json:"counts"`
+}
+
+// User is a user referred to by the archive.
+type User struct {
+	ID       string `json:"id"`
+	Email    string `json:"email"`
+	FullName string `json:"fullName"`
+}
+
+// Export writes the team's archive to w.
+func Export(ctx context.Context, w io.Writer, teamID primitive.ObjectID) (Manifest, error) {
+	db := database.DB
+	manifest := Manifest{Format: Format, Version: Version, ExportedAt: time.Now(), TeamID: teamID.Hex(), Counts: map[string]int{}}
+
+	var team models.Team
+	err := db.Collection("teams").FindOne(ctx, bson.M{"_id": teamID}).Decode(&team)
+	if err == mongo.ErrNoDocuments {
+		return manifest, ErrTeamNotFound
+	}
+	if err != nil {
+		return manifest, err
+	}
+	manifest.TeamName = team.Name
+
+	zw := zip.NewWriter(w)
+	users := map[string]bool{team.CreatedBy: true}
+	projectIDs := []primitive.ObjectID{}
+
+	err = writeFile(zw, "team.ndjson", manifest.Counts, func(write func(interface{}) error) error {
+		return write(team)
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFind(ctx, zw, "members.ndjson", manifest.Counts, db.Collection("team-members"), bson.M{"teamId": teamID}, func(m *models.TeamMember) {
+		users[m.User] = true
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFind(ctx, zw, "projects.ndjson", manifest.Counts, db.Collection("projects"), bson.M{"teamId": teamID}, func(p *models.Project) {
+		users[p.CreatedBy] = true
+		projectIDs = append(projectIDs, p.ID)
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFind(ctx, zw, "sprints.ndjson", manifest.Counts, db.Collection("sprints"), bson.M{"projectId": bson.M{"$in": projectIDs}}, func(s *models.Sprint) {
+		users[s.CreatedBy] = true
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFind(ctx, zw, "tasks.ndjson", manifest.Counts, db.Collection("tasks"), bson.M{"projectId": bson.M{"$in": projectIDs}}, func(t *models.Task) {
+		users[t.CreatedBy] = true
+		if !t.AssignedTo.IsZero() {
+			users[t.AssignedTo.Hex()] = true
+		}
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFind(ctx, zw, "templates.ndjson", manifest.Counts, db.Collection("templates"), bson.M{"teamId": teamID}, func(t *models.ProjectTemplate) {
+		users[t.CreatedBy] = true
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFind(ctx, zw, "messages.ndjson", manifest.Counts, db.Collection("messages"), bson.M{"temaid": teamID.Hex()}, func(m *models.Message) {
+		users[m.User] = true
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFind(ctx, zw, "activity.ndjson", manifest.Counts, db.Collection("activity-log"), bson.M{"teamID": teamID.Hex()}, func(a *models.ActivityLog) {
+		users[a.UserID] = true
+		users[a.TargetUserID] = true
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	userIDs := []primitive.ObjectID{}
+	for id := range users {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			userIDs = append(userIDs, objID)
+		}
+	}
+	cursor, err := db.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return manifest, err
+	}
+	defer cursor.Close(ctx)
+	err = writeFile(zw, "users.ndjson", manifest.Counts, func(write func(interface{}) error) error {
+		for cursor.Next(ctx) {
+			var u models.User
+			if err := cursor.Decode(&u); err != nil {
+				return err
+			}
+			if err := write(User{ID: u.ID.Hex(), Email: u.Email, FullName: u.FullName}); err != nil {
+				return err
+			}
+		}
+		return cursor.Err()
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	err = writeFile(zw, "manifest.json", nil, func(write func(interface{}) error) error {
+		return write(manifest)
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	return manifest, zw.Close()
+}
+
+// writeFile adds an NDJSON file, counting its lines when counts is set.
+func writeFile(zw *zip.Writer, name string, counts map[string]int, fill func(write func(interface{}) error) error) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(f)
+	return fill(func(v interface{}) error {
+		if counts != nil {
+			counts[name]++
+		}
+		return encoder.Encode(v)
+	})
+}
+
+// writeFind adds an NDJSON file with the documents matching filter, calling
+// each on every one before it is written.
+func writeFind[T any](ctx context.Context, zw *zip.Writer, name string, counts map[string]int, collection *mongo.Collection, filter bson.M, each func(*T)) error {
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return writeFile(zw, name, counts, func(write func(interface{}) error) error {
+		for cursor.Next(ctx) {
+			var doc T
+			if err := cursor.Decode(&doc); err != nil {
+				return err
+			}
+			each(&doc)
+			if err := write(doc); err != nil {
+				return err
+			}
+		}
+		return cursor.Err()
+	})
+}
@@ -0,0 +1,505 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+)
+
+var ErrInvalidArchive = errors.New("Invalid archive")
+
+const (
+	// maxUncompressed bounds what an archive may expand to, so a small upload
+	// can't be a zip bomb. Exported NDJSON compresses about tenfold.
+	maxUncompressed = 512 << 20
+	// maxRecords bounds the records read from any one file.
+	maxRecords = 250000
+)
+
+// ImportOptions controls how an archive becomes a team.
+type ImportOptions struct {
+	// Owner is made an admin of the new team and stands in as the creator
+	// of anything whose creator has no account here.
+	Owner models.User
+	// Name replaces the team's name when set.
+	Name string
+	// MatchUsers matches the archive's users to accounts here by email and
+	// keeps their memberships, assignments and authorship. Without it only
+	// the owner is matched, by their own email, so whoever uploads an archive
+	// learns nothing about which emails have accounts.
+	MatchUsers bool
+}
+
+// ImportResult describes the team an import created.
+type ImportResult struct {
+	TeamID   primitive.ObjectID `json:"teamId"`
+	TeamName string             `json:"teamName"`
+	Counts   map[string]int     `json:"counts"`
+	// UnmatchedEmails are users in the archive with no account here, with
+	// MatchUsers. Their memberships are dropped and their tasks left
+	// unassigned.
+	UnmatchedEmails []string `json:"unmatchedEmails,omitempty"`
+}
+
+// importer remaps the archive's IDs to new ones as records are read.
+type importer struct {
+	ctx   context.Context
+	zr    *zip.Reader
+	owner string
+	ids   map[primitive.ObjectID]primitive.ObjectID
+	users map[string]string
+}
+
+// Import creates a new team from the archive in r. Every ID is replaced, and
+// users are matched to accounts here by email as opts says. If anything fails,
+// what was imported so far is removed again.
+func Import(ctx context.Context, r io.ReaderAt, size int64, opts ImportOptions) (*ImportResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	// The zip reader refuses to inflate a file past its declared size, so
+	// checking the declared sizes bounds the whole import.
+	var total uint64
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > maxUncompressed-total {
+			return nil, fmt.Errorf("%w: expands to more than %d MB", ErrInvalidArchive, maxUncompressed>>20)
+		}
+		total += f.UncompressedSize64
+	}
+
+	im := &importer{
+		ctx:   ctx,
+		zr:    zr,
+		owner: opts.Owner.ID.Hex(),
+		ids:   map[primitive.ObjectID]primitive.ObjectID{},
+		users: map[string]string{},
+	}
+
+	var manifest Manifest
+	err = readFile(zr, "manifest.json", func(m *Manifest) error {
+		manifest = *m
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Format != Format {
+		return nil, fmt.Errorf("%w: not a team archive", ErrInvalidArchive)
+	}
+	if manifest.Version > Version {
+		return nil, fmt.Errorf("%w: version %d is newer than this server supports", ErrInvalidArchive, manifest.Version)
+	}
+
+	result := &ImportResult{Counts: map[string]int{}}
+	if opts.MatchUsers {
+		if result.UnmatchedEmails, err = im.matchUsers(); err != nil {
+			return nil, err
+		}
+	} else if err = im.matchOwner(opts.Owner); err != nil {
+		return nil, err
+	}
+
+	var team models.Team
+	teams := 0
+	err = readFile(zr, "team.ndjson", func(t *models.Team) error {
+		team = *t
+		teams++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if teams != 1 {
+		return nil, fmt.Errorf("%w: expected one team, found %d", ErrInvalidArchive, teams)
+	}
+
+	members, err := im.members()
+	if err != nil {
+		return nil, err
+	}
+
+	team.ID = im.id(team.ID)
+	team.CreatedBy = im.creator(team.CreatedBy)
+	if opts.Name != "" {
+		team.Name = opts.Name
+	}
+	team.Members = []string{}
+	for _, m := range members {
+		team.Members = append(team.Members, m.User)
+	}
+	projects := team.Projects
+	team.Projects = []primitive.ObjectID{}
+	for _, id := range projects {
+		team.Projects = append(team.Projects, im.id(id))
+	}
+
+	result.TeamID = team.ID
+	result.TeamName = team.Name
+
+	if err := im.insert(team, members, result.Counts); err != nil {
+		rollback(ctx, team.ID, members)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (im *importer) insert(team models.Team, members []models.TeamMember, counts map[string]int) error {
+	db := database.DB
+	teamHex := team.ID.Hex()
+
+	if _, err := db.Collection("teams").InsertOne(im.ctx, team); err != nil {
+		return err
+	}
+	counts["teams"] = 1
+
+	memberBatch := im.batch("team-members", counts)
+	var memberIDs []primitive.ObjectID
+	for _, m := range members {
+		m.TeamId = team.ID
+		if err := memberBatch.add(m); err != nil {
+			return err
+		}
+		userID, _ := primitive.ObjectIDFromHex(m.User)
+		memberIDs = append(memberIDs, userID)
+	}
+	if err := memberBatch.flush(); err != nil {
+		return err
+	}
+	_, err := db.Collection("users").UpdateMany(im.ctx, bson.M{"_id": bson.M{"$in": memberIDs}}, bson.M{"$addToSet": bson.M{"teams": team.ID}})
+	if err != nil {
+		return err
+	}
+
+	projects := im.batch("projects", counts)
+	err = readFile(im.zr, "projects.ndjson", func(p *models.Project) error {
+		p.ID = im.id(p.ID)
+		p.TeamId = team.ID
+		p.CreatedBy = im.creator(p.CreatedBy)
+		tasks := p.Tasks
+		p.Tasks = []string{}
+		for _, id := range tasks {
+			p.Tasks = append(p.Tasks, im.idHex(id))
+		}
+		return projects.add(p)
+	})
+	if err == nil {
+		err = projects.flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	sprints := im.batch("sprints", counts)
+	err = readFile(im.zr, "sprints.ndjson", func(s *models.Sprint) error {
+		s.ID = im.id(s.ID)
+		s.ProjectId = im.id(s.ProjectId)
+		s.TeamId = team.ID
+		s.CreatedBy = im.creator(s.CreatedBy)
+		return sprints.add(s)
+	})
+	if err == nil {
+		err = sprints.flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	tasks := im.batch("tasks", counts)
+	err = readFile(im.zr, "tasks.ndjson", func(t *models.Task) error {
+		t.ID = im.id(t.ID)
+		t.TeamId = team.ID
+		t.ProjectId = im.id(t.ProjectId)
+		if t.SprintId != nil {
+			id := im.id(*t.SprintId)
+			t.SprintId = &id
+		}
+		if t.RecurrenceOf != nil {
+			id := im.id(*t.RecurrenceOf)
+			t.RecurrenceOf = &id
+		}
+		t.AssignedTo, _ = primitive.ObjectIDFromHex(im.user(t.AssignedTo.Hex()))
+		t.CreatedBy = im.creator(t.CreatedBy)
+		// Archives from before ranks existed only carry the name.
+		t.PriorityRank = models.TaskPriorities[t.Priority]
+		// The archive's next run may be long past, or made up; the rule
+		// restarts from now as it does when it is set.
+		t.NextRunAt = nil
+		if t.Recurrence != nil {
+			if next, ok := services.NextOccurrence(*t.Recurrence, time.Now()); ok {
+				t.NextRunAt = &next
+			}
+		}
+		return tasks.add(t)
+	})
+	if err == nil {
+		err = tasks.flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	templates := im.batch("templates", counts)
+	err = readFile(im.zr, "templates.ndjson", func(t *models.ProjectTemplate) error {
+		t.ID = im.id(t.ID)
+		t.TeamId = team.ID
+		t.CreatedBy = im.creator(t.CreatedBy)
+		return templates.add(t)
+	})
+	if err == nil {
+		err = templates.flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	messages := im.batch("messages", counts)
+	err = readFile(im.zr, "messages.ndjson", func(m *models.Message) error {
+		m.ID = im.id(m.ID)
+		m.TeamId = teamHex
+		m.User = im.creator(m.User)
+		return messages.add(m)
+	})
+	if err == nil {
+		err = messages.flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	activity := im.batch("activity-log", counts)
+	err = readFile(im.zr, "activity.ndjson", func(a *models.ActivityLog) error {
+		a.ID = im.id(a.ID)
+		a.TeamID = teamHex
+		a.ProjectID = im.idHex(a.ProjectID)
+		a.TaskID = im.idHex(a.TaskID)
+		a.UserID = im.creator(a.UserID)
+		a.TargetUserID = im.user(a.TargetUserID)
+		return activity.add(a)
+	})
+	if err == nil {
+		err = activity.flush()
+	}
+	return err
+}
+
+// matchUsers maps the archive's users to accounts with the same email and
+// returns the emails that have none.
+func (im *importer) matchUsers() ([]string, error) {
+	emails := map[string][]string{}
+	err := readFile(im.zr, "users.ndjson", func(u *User) error {
+		email := strings.TrimSpace(u.Email)
+		if email != "" {
+			emails[email] = append(emails[email], u.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(emails))
+	for email := range emails {
+		list = append(list, email)
+	}
+
+	cursor, err := database.DB.Collection("users").Find(im.ctx, bson.M{"email": bson.M{"$in": list}, "serviceAccount": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	var found []models.User
+	if err := cursor.All(im.ctx, &found); err != nil {
+		return nil, err
+	}
+
+	for _, user := range found {
+		for _, old := range emails[user.Email] {
+			im.users[old] = user.ID.Hex()
+		}
+		delete(emails, user.Email)
+	}
+
+	unmatched := make([]string, 0, len(emails))
+	for email := range emails {
+		unmatched = append(unmatched, email)
+	}
+	sort.Strings(unmatched)
+	return unmatched, nil
+}
+
+// matchOwner maps the owner's archived user, if any, to the owner.
+func (im *importer) matchOwner(owner models.User) error {
+	return readFile(im.zr, "users.ndjson", func(u *User) error {
+		if strings.EqualFold(strings.TrimSpace(u.Email), owner.Email) {
+			im.users[u.ID] = im.owner
+		}
+		return nil
+	})
+}
+
+// members returns the memberships to create: matched users only, once each,
+// with the owner as an admin.
+func (im *importer) members() ([]models.TeamMember, error) {
+	var members []models.TeamMember
+	seen := map[string]int{}
+
+	err := readFile(im.zr, "members.ndjson", func(m *models.TeamMember) error {
+		user := im.user(m.User)
+		if user == "" {
+			return nil
+		}
+		if _, ok := seen[user]; ok {
+			return nil
+		}
+		seen[user] = len(members)
+
+		m.ID = primitive.NewObjectID()
+		m.User = user
+		members = append(members, *m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if i, ok := seen[im.owner]; ok {
+		members[i].Role = "Admin"
+	} else {
+		members = append(members, models.TeamMember{ID: primitive.NewObjectID(), User: im.owner, Role: "Admin", JoinedAt: time.Now()})
+	}
+	return members, nil
+}
+
+// id returns the new ID for an archived one. The zero ID stays zero.
+func (im *importer) id(old primitive.ObjectID) primitive.ObjectID {
+	if old.IsZero() {
+		return old
+	}
+	id, ok := im.ids[old]
+	if !ok {
+		id = primitive.NewObjectID()
+		im.ids[old] = id
+	}
+	return id
+}
+
+func (im *importer) idHex(old string) string {
+	id, err := primitive.ObjectIDFromHex(old)
+	if err != nil {
+		return ""
+	}
+	return im.id(id).Hex()
+}
+
+// user returns the matched account for an archived user ID, or "".
+func (im *importer) user(old string) string {
+	return im.users[old]
+}
+
+// creator is like user but falls back to the owner.
+func (im *importer) creator(old string) string {
+	if user := im.user(old); user != "" {
+		return user
+	}
+	return im.owner
+}
+
+// rollback removes a partly imported team.
+func rollback(ctx context.Context, teamID primitive.ObjectID, members []models.TeamMember) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	db := database.DB
+	teamHex := teamID.Hex()
+	db.Collection("teams").DeleteOne(ctx, bson.M{"_id": teamID})
+	db.Collection("team-members").DeleteMany(ctx, bson.M{"teamId": teamID})
+	db.Collection("projects").DeleteMany(ctx, bson.M{"teamId": teamID})
+	db.Collection("sprints").DeleteMany(ctx, bson.M{"teamId": teamID})
+	db.Collection("tasks").DeleteMany(ctx, bson.M{"teamid": teamID})
+	db.Collection("templates").DeleteMany(ctx, bson.M{"teamId": teamID})
+	db.Collection("messages").DeleteMany(ctx, bson.M{"temaid": teamHex})
+	db.Collection("activity-log").DeleteMany(ctx, bson.M{"teamID": teamHex})
+	db.Collection("users").UpdateMany(ctx, bson.M{"teams": teamID}, bson.M{"$pull": bson.M{"teams": teamID}})
+}
+
+// batch buffers inserts into one collection.
+type batch struct {
+	ctx        context.Context
+	collection *mongo.Collection
+	name       string
+	counts     map[string]int
+	docs       []interface{}
+}
+
+const batchSize = 500
+
+func (im *importer) batch(collection string, counts map[string]int) *batch {
+	return &batch{ctx: im.ctx, collection: database.DB.Collection(collection), name: collection, counts: counts}
+}
+
+func (b *batch) add(doc interface{}) error {
+	b.docs = append(b.docs, doc)
+	if len(b.docs) >= batchSize {
+		return b.flush()
+	}
+	return nil
+}
+
+func (b *batch) flush() error {
+	if len(b.docs) == 0 {
+		return nil
+	}
+	if _, err := b.collection.InsertMany(b.ctx, b.docs); err != nil {
+		return err
+	}
+	b.counts[b.name] += len(b.docs)
+	b.docs = b.docs[:0]
+	return nil
+}
+
+// readFile decodes each line of an NDJSON file in the archive. A missing
+// file has no records, except the manifest, which is required.
+func readFile[T any](zr *zip.Reader, name string, each func(*T) error) error {
+	f, err := zr.Open(name)
+	if errors.Is(err, fs.ErrNotExist) && name != "manifest.json" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(io.LimitReader(f, maxUncompressed))
+	for records := 0; ; records++ {
+		var record T
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+		}
+		if records == maxRecords {
+			return fmt.Errorf("%w: %s has more than %d records", ErrInvalidArchive, name, maxRecords)
+		}
+		if err := each(&record); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Command collabctl is the operator CLI. It reads the same configuration as
+// the server (flags, environment and .env) and works on the same database
+// through the same store code, so nobody has to edit documents by hand.
+//
+//	collabctl [server flags] <command> [command flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/Loboo34/collab-api/config"
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+type command struct {
+	usage string
+	// run defines its flags on fs and parses args with it.
+	run func(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]command{
+	"user create":         {"-email <email> [-name <name>] [-password-stdin]", createUser},
+	"user reset-password": {"-user <email|id> [-password-stdin]", resetPassword},
+	"team list":           {"", listTeams},
+	"team members":        {"-team <id>", listMembers},
+	"team set-role":       {"-team <id> -user <email|id> -role Admin|Member", setRole},
+	"team export":         {"-team <id> [-out <file>]", exportTeam},
+	"team import":         {"-file <file> -owner <email|id> [-name <name>]", importTeam},
+	"invite resend":       {"-invite <id> | -team <id> -email <email>", resendInvite},
+	"migrate":             {"", runMigrations},
+	"migrate status":      {"", migrationStatus},
+	"purge":               {"[-yes]", purge},
+}
+
+func main() {
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	name, cmd, ok := lookup(args)
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	args = args[len(strings.Fields(name)):]
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	database.ConnectDB(cfg.MongoURI.Value(), cfg.MongoDatabase)
+	defer database.Client.Disconnect(context.Background())
+	utils.InitLogger()
+	defer utils.SyncLogger()
+	utils.InitEmail(cfg.SMTP)
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: collabctl %s %s\n", name, cmd.usage)
+		fs.PrintDefaults()
+	}
+
+	if err := cmd.run(ctx, cfg, fs, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fail(err)
+	}
+}
+
+// lookup finds the command named by the leading words of args, preferring
+// the longest name.
+func lookup(args []string) (string, command, bool) {
+	for n := 2; n >= 1; n-- {
+		if len(args) < n {
+			continue
+		}
+		name := strings.Join(args[:n], " ")
+		if cmd, ok := commands[name]; ok {
+			return name, cmd, true
+		}
+	}
+	return "", command{}, false
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: collabctl [server flags] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "collabctl:", err)
+	os.Exit(1)
+}
+
+// table prints rows aligned in columns.
+func table(header string, rows [][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, header)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
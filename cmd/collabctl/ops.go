@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Loboo34/collab-api/config"
+	"github.com/Loboo34/collab-api/migrations"
+	"github.com/Loboo34/collab-api/services"
+)
+
+func runMigrations(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := migrations.Run(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Database is up to date")
+	return nil
+}
+
+func migrationStatus(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	statuses, err := migrations.Statuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(statuses))
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{strconv.Itoa(s.Version), s.Name, applied})
+	}
+	table("VERSION\tNAME\tAPPLIED", rows)
+	return nil
+}
+
+func purge(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	yes := fs.Bool("yes", false, "delete; without it only counts what would be deleted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	found, err := services.PurgeOrphans(ctx, !*yes)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		fmt.Println("Nothing to purge")
+		return nil
+	}
+
+	rows := make([][]string, 0, len(found))
+	for collection, count := range found {
+		rows = append(rows, []string{collection, strconv.FormatInt(count, 10)})
+	}
+	sortRows(rows)
+
+	if *yes {
+		table("PURGED\tCOUNT", rows)
+	} else {
+		table("WOULD PURGE\tCOUNT", rows)
+		fmt.Println("Run again with -yes to delete")
+	}
+	return nil
+}
+
+func sortRows(rows [][]string) {
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+}
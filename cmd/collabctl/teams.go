@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/archive"
+	"github.com/Loboo34/collab-api/config"
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+func listTeams(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := database.DB.Collection("teams").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+
+	var teams []models.Team
+	if err := cursor.All(ctx, &teams); err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(teams))
+	for _, team := range teams {
+		rows = append(rows, []string{
+			team.ID.Hex(), team.Name, strconv.Itoa(len(team.Members)), strconv.Itoa(len(team.Projects)), team.CreatedAt.Format(time.DateOnly),
+		})
+	}
+	table("ID\tNAME\tMEMBERS\tPROJECTS\tCREATED", rows)
+	return nil
+}
+
+func listMembers(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	teamRef := fs.String("team", "", "the team's ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	team, err := findTeam(ctx, *teamRef)
+	if err != nil {
+		return err
+	}
+	members, err := services.TeamMembers(ctx, team.ID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(members))
+	for _, m := range members {
+		if id, err := primitive.ObjectIDFromHex(m.User); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	cursor, err := database.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+	emails := map[string]string{}
+	for _, u := range users {
+		emails[u.ID.Hex()] = u.Email
+	}
+
+	rows := make([][]string, 0, len(members))
+	for _, m := range members {
+		rows = append(rows, []string{m.User, emails[m.User], m.Role, m.JoinedAt.Format(time.DateOnly)})
+	}
+	table("USER\tEMAIL\tROLE\tJOINED", rows)
+	return nil
+}
+
+func setRole(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	teamRef := fs.String("team", "", "the team's ID")
+	userRef := fs.String("user", "", "the member's email or ID")
+	role := fs.String("role", "", "Admin or Member")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *role != "Admin" && *role != "Member" {
+		return errors.New("-role must be Admin or Member")
+	}
+	team, err := findTeam(ctx, *teamRef)
+	if err != nil {
+		return err
+	}
+	user, err := findUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	if err := services.SetMemberRole(ctx, team.ID, user.ID.Hex(), *role); err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s in %s\n", user.Email, *role, team.Name)
+	return nil
+}
+
+func exportTeam(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	teamRef := fs.String("team", "", "the team's ID")
+	out := fs.String("out", "", "the archive to write (default team-<id>.zip)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	team, err := findTeam(ctx, *teamRef)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = "team-" + team.ID.Hex() + ".zip"
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	manifest, err := archive.Export(ctx, f, team.ID)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	fmt.Printf("Exported %s to %s\n", team.Name, *out)
+	printCounts(manifest.Counts)
+	return nil
+}
+
+func importTeam(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	file := fs.String("file", "", "the archive to import")
+	ownerRef := fs.String("owner", "", "email or ID of the user who becomes the team's admin")
+	name := fs.String("name", "", "name for the new team (default the archived name)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	owner, err := findUser(ctx, *ownerRef)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	result, err := archive.Import(ctx, f, info.Size(), archive.ImportOptions{Owner: owner, Name: *name, MatchUsers: true})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %s as team %s\n", result.TeamName, result.TeamID.Hex())
+	printCounts(result.Counts)
+	if len(result.UnmatchedEmails) > 0 {
+		fmt.Println("No account for:", strings.Join(result.UnmatchedEmails, ", "))
+	}
+	return nil
+}
+
+func resendInvite(ctx context.Context, cfg *config.Config, fs *flag.FlagSet, args []string) error {
+	inviteRef := fs.String("invite", "", "the invite's ID")
+	teamRef := fs.String("team", "", "the team's ID, with -email")
+	email := fs.String("email", "", "the invited email, with -team")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var inviteID primitive.ObjectID
+	switch {
+	case *inviteRef != "":
+		id, err := primitive.ObjectIDFromHex(*inviteRef)
+		if err != nil {
+			return errors.New("-invite must be an invite ID")
+		}
+		inviteID = id
+	case *teamRef != "" && *email != "":
+		team, err := findTeam(ctx, *teamRef)
+		if err != nil {
+			return err
+		}
+		var invite models.Invite
+		err = database.DB.Collection("invites").FindOne(ctx, bson.M{"teamId": team.ID, "email": *email, "status": "pending"}).Decode(&invite)
+		if err == mongo.ErrNoDocuments {
+			return services.ErrInviteNotFound
+		}
+		if err != nil {
+			return err
+		}
+		inviteID = invite.ID
+	default:
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	invite, err := services.RenewInvite(ctx, inviteID)
+	if err != nil {
+		return err
+	}
+	if err := utils.SendInviteEmail(invite.Email, cfg.AppURL+"/invite/accept?token="+invite.Token); err != nil {
+		return err
+	}
+
+	fmt.Printf("Sent a new invite to %s, valid until %s\n", invite.Email, invite.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+func findTeam(ctx context.Context, ref string) (models.Team, error) {
+	var team models.Team
+	id, err := primitive.ObjectIDFromHex(ref)
+	if err != nil {
+		return team, errors.New("-team must be a team ID")
+	}
+
+	err = database.DB.Collection("teams").FindOne(ctx, bson.M{"_id": id}).Decode(&team)
+	if err == mongo.ErrNoDocuments {
+		return team, services.ErrTeamNotFound
+	}
+	return team, err
+}
+
+func printCounts(counts map[string]int) {
+	rows := make([][]string, 0, len(counts))
+	for name, count := range counts {
+		rows = append(rows, []string{strings.TrimSuffix(name, ".ndjson"), strconv.Itoa(count)})
+	}
+	sortRows(rows)
+	table("RECORDS\tCOUNT", rows)
+}
@@ -1,3 +1,266 @@
+// Package config loads the server's settings. Values come from, in order of
+// precedence: command-line flags, environment variables, an optional
+// dotenv-style file (-config, CONFIG_FILE or ./.env) and defaults.
 package config
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/joho/godotenv"
+)
+
+// Secret is a setting that must not end up in logs. It prints as
+// "[REDACTED]"; use Value for the real thing.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type SMTP struct {
+	Host     string
+	Port     int
+	Email    string
+	Password Secret
+}
+
+type Config struct {
+	Port          int
+	MongoURI      Secret // may carry credentials
+	MongoDatabase string
+	JWTSecret     Secret
+
+	// AllowedOrigins are the browser origins CORS lets in.
+	AllowedOrigins []string
+	// AppURL is the frontend's base URL, used to build links in emails.
+	AppURL string
+
+	SMTP SMTP
+
+	// TrustProxy makes the client IP come from the last X-Forwarded-For
+	// entry, which suits a single reverse proxy in front of the API.
+	TrustProxy   bool
+	MetricsToken Secret
+
+	ShutdownTimeout time.Duration
+
+	// MigrateOnStart applies pending migrations when the server starts.
+	// When off, the server refuses to start until "migrate" has been run.
+	MigrateOnStart bool
+}
+
+func defaults() Config {
+	return Config{
+		Port:            8080,
+		MongoDatabase:   "collab",
+		AllowedOrigins:  []string{"http://localhost:3000", "http://localhost:5173"},
+		AppURL:          "http://localhost:3000",
+		SMTP:            SMTP{Host: "smtp.gmail.com", Port: 587},
+		ShutdownTimeout: 30 * time.Second,
+		MigrateOnStart:  true,
+	}
+}
+
+// Load builds the configuration from args (without the program name) and
+// the environment, and returns the arguments left after the flags. Every
+// invalid setting is reported in the one error.
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	file := flags.String("config", "", "dotenv-style config `file` (default CONFIG_FILE or ./.env)")
+	port := flags.Int("port", 0, "port to listen on (PORT)")
+	mongoURI := flags.String("mongo-uri", "", "MongoDB connection string (MONGO_URI)")
+	mongoDatabase := flags.String("mongo-db", "", "MongoDB database name (MONGO_DB)")
+	appURL := flags.String("app-url", "", "frontend base URL used in emails (APP_URL)")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	// The file fills in variables the environment doesn't set, so other
+	// packages reading their own variables (OIDC_*, OTEL_*) see it too.
+	path := *file
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := godotenv.Load(path); err != nil {
+			return nil, nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	} else if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(".env"); err != nil {
+			return nil, nil, fmt.Errorf("config file .env: %w", err)
+		}
+	}
+
+	cfg := defaults()
+	var problems []error
+
+	fromEnv := func(name string, apply func(string) error) {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return
+		}
+		if err := apply(value); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	setString := func(target *string) func(string) error {
+		return func(v string) error { *target = v; return nil }
+	}
+	setSecret := func(target *Secret) func(string) error {
+		return func(v string) error { *target = Secret(v); return nil }
+	}
+	setInt := func(target *int) func(string) error {
+		return func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return errors.New("must be a number")
+			}
+			*target = n
+			return nil
+		}
+	}
+	setBool := func(target *bool) func(string) error {
+		return func(v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return errors.New("must be true or false")
+			}
+			*target = b
+			return nil
+		}
+	}
+
+	fromEnv("PORT", setInt(&cfg.Port))
+	fromEnv("MONGO_URI", setSecret(&cfg.MongoURI))
+	fromEnv("MONGO_DB", setString(&cfg.MongoDatabase))
+	fromEnv("JWT_SECRET", setSecret(&cfg.JWTSecret))
+	fromEnv("ALLOWED_ORIGINS", func(v string) error {
+		cfg.AllowedOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+			}
+		}
+		return nil
+	})
+	fromEnv("APP_URL", setString(&cfg.AppURL))
+	fromEnv("SMTP_HOST", setString(&cfg.SMTP.Host))
+	fromEnv("SMTP_PORT", setInt(&cfg.SMTP.Port))
+	fromEnv("SMTP_EMAIL", setString(&cfg.SMTP.Email))
+	fromEnv("SMTP_PASSWORD", setSecret(&cfg.SMTP.Password))
+	fromEnv("TRUST_PROXY", setBool(&cfg.TrustProxy))
+	fromEnv("METRICS_TOKEN", setSecret(&cfg.MetricsToken))
+	fromEnv("SHUTDOWN_TIMEOUT", func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return errors.New("must be a duration such as 30s")
+		}
+		cfg.ShutdownTimeout = d
+		return nil
+	})
+	fromEnv("MIGRATE_ON_START", setBool(&cfg.MigrateOnStart))
+
+	if *port != 0 {
+		cfg.Port = *port
+	}
+	if *mongoURI != "" {
+		cfg.MongoURI = Secret(*mongoURI)
+	}
+	if *mongoDatabase != "" {
+		cfg.MongoDatabase = *mongoDatabase
+	}
+	if *appURL != "" {
+		cfg.AppURL = *appURL
+	}
+
+	cfg.AppURL = strings.TrimSuffix(cfg.AppURL, "/")
+
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+	}
+	return &cfg, flags.Args(), nil
+}
+
+func (c Config) validate() []error {
+	var problems []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, errors.New("PORT: must be between 1 and 65535"))
+	}
+
+	uri := c.MongoURI.Value()
+	if uri == "" {
+		problems = append(problems, errors.New("MONGO_URI: required"))
+	} else if !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://") {
+		problems = append(problems, errors.New("MONGO_URI: must start with mongodb:// or mongodb+srv://"))
+	}
+	if c.MongoDatabase == "" {
+		problems = append(problems, errors.New("MONGO_DB: required"))
+	}
+
+	if c.JWTSecret == "" {
+		problems = append(problems, errors.New("JWT_SECRET: required"))
+	} else if len(c.JWTSecret) < 16 {
+		problems = append(problems, errors.New("JWT_SECRET: must be at least 16 characters"))
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		if !absoluteHTTPURL(origin) {
+			problems = append(problems, fmt.Errorf("ALLOWED_ORIGINS: %q is not an http or https origin", origin))
+		}
+	}
+	if !absoluteHTTPURL(c.AppURL) {
+		problems = append(problems, errors.New("APP_URL: must be an absolute http or https URL"))
+	}
+
+	if c.SMTP.Email != "" && c.SMTP.Password == "" {
+		problems = append(problems, errors.New("SMTP_PASSWORD: required when SMTP_EMAIL is set"))
+	}
+	if c.SMTP.Port < 1 || c.SMTP.Port > 65535 {
+		problems = append(problems, errors.New("SMTP_PORT: must be between 1 and 65535"))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, errors.New("SHUTDOWN_TIMEOUT: must be positive"))
+	}
+
+	return problems
+}
+
+func absoluteHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// String describes the configuration for startup logs, with secrets redacted.
+func (c Config) String() string {
+	return fmt.Sprintf(
+		"port=%d mongo_uri=%s mongo_db=%s jwt_secret=%s allowed_origins=%s app_url=%s smtp=%s:%d smtp_email=%s smtp_password=%s trust_proxy=%t metrics_token=%s shutdown_timeout=%s migrate_on_start=%t",
+		c.Port, c.MongoURI, c.MongoDatabase, c.JWTSecret, strings.Join(c.AllowedOrigins, ","), c.AppURL,
+		c.SMTP.Host, c.SMTP.Port, c.SMTP.Email, c.SMTP.Password, c.TrustProxy, c.MetricsToken, c.ShutdownTimeout, c.MigrateOnStart,
+	)
+}
@@ -3,19 +3,22 @@ package database
 import (
 	"context"
 	"fmt"
-	"os"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+
+	"github.com/Loboo34/collab-api/metrics"
 )
 
 var Client *mongo.Client
 var DB *mongo.Database
 
-func ConnectDB() *mongo.Database {
-	var mongoUri = os.Getenv("MONGO_URI")
-	clientOptions := options.Client().ApplyURI(mongoUri)
+func ConnectDB(mongoUri, name string) *mongo.Database {
+	monitor := chainMonitors(otelmongo.NewMonitor(), metrics.CommandMonitor())
+	clientOptions := options.Client().ApplyURI(mongoUri).SetMonitor(monitor)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -32,8 +35,36 @@ func ConnectDB() *mongo.Database {
 
 	fmt.Println("Connected to DB successfuly")
 	Client = client
-	DB = client.Database("collab")
+	DB = client.Database(name)
 
 	return DB
 
 }
+
+// chainMonitors lets both tracing and metrics watch driver commands, since
+// the client takes a single monitor.
+func chainMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
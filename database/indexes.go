@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the API relies on. Creating an index that
+// already exists with the same definition is a no-op, so this is safe on every start.
+func EnsureIndexes(ctx context.Context) error {
+	indexes := map[string][]mongo.IndexModel{
+		"tasks": {
+			{
+				Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+				Options: options.Index().SetName("tasks_text").SetWeights(bson.D{{Key: "title", Value: 3}, {Key: "description", Value: 1}}),
+			},
+			// List filters and sorts, all scoped to one project.
+			{Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "status", Value: 1}, {Key: "position", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "dueDate", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "assigned", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "labels", Value: 1}}},
+			{Keys: bson.D{{Key: "assigned", Value: 1}, {Key: "dueDate", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "assigned", Value: 1}, {Key: "priorityRank", Value: -1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "teamid", Value: 1}, {Key: "completedAt", Value: 1}}},
+		},
+		"projects": {
+			{
+				Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+				Options: options.Index().SetName("projects_text").SetWeights(bson.D{{Key: "name", Value: 3}, {Key: "description", Value: 1}}),
+			},
+			{Keys: bson.D{{Key: "teamId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "teamId", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		},
+		"users": {
+			{Keys: bson.D{{Key: "digest", Value: 1}, {Key: "digestSentAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{
+				Keys:    bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"identities": bson.M{"$exists": true}}),
+			},
+		},
+		"teams": {
+			{Keys: bson.D{{Key: "members", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+		},
+		"team-members": {
+			{Keys: bson.D{{Key: "teamId", Value: 1}, {Key: "joinedat", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "teamId", Value: 1}, {Key: "role", Value: 1}}},
+		},
+		"messages": {
+			{
+				Keys:    bson.D{{Key: "content", Value: "text"}},
+				Options: options.Index().SetName("messages_text"),
+			},
+		},
+		"notifications": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "read", Value: 1}}},
+		},
+		"webhooks": {
+			{Keys: bson.D{{Key: "teamId", Value: 1}, {Key: "active", Value: 1}, {Key: "events", Value: 1}}},
+		},
+		"webhook-deliveries": {
+			// The retry queue; delivered and failed entries drop out of it.
+			{
+				Keys:    bson.D{{Key: "nextAttemptAt", Value: 1}},
+				Options: options.Index().SetPartialFilterExpression(bson.M{"status": "pending"}),
+			},
+			{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}},
+		},
+		// Failures are forgotten after an hour without another one.
+		"login-attempts": {
+			{Keys: bson.D{{Key: "lastFailureAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(3600)},
+		},
+		"oidc-logins": {
+			{Keys: bson.D{{Key: "state", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(600)},
+		},
+		"api-tokens": {
+			{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		"inbound-hooks": {
+			{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "projectId", Value: 1}}},
+		},
+		"inbound-deliveries": {
+			{Keys: bson.D{{Key: "hookId", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"activity-log": {
+			// Status changes, aggregated by the stats endpoints.
+			{Keys: bson.D{{Key: "projectID", Value: 1}, {Key: "toStatus", Value: 1}}},
+			{Keys: bson.D{{Key: "teamID", Value: 1}, {Key: "toStatus", Value: 1}}},
+			// Assignments picked up by the email digest.
+			{Keys: bson.D{{Key: "targetUserID", Value: 1}, {Key: "action", Value: 1}, {Key: "timestamp", Value: 1}}},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := DB.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
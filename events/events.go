@@ -0,0 +1,97 @@
+// Package events is an in-process dispatcher for domain events. Handlers
+// publish what happened; subscribers such as notifications react to it
+// without the handlers knowing about them.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/utils"
+)
+
+const (
+	TaskCreated       = "task.created"
+	TaskAssigned      = "task.assigned"
+	TaskStatusChanged = "task.status_changed"
+	MemberInvited     = "member.invited"
+	MemberJoined      = "member.joined"
+	ProjectDeleted    = "project.deleted"
+)
+
+// AccountLocked is published when repeated failed logins lock an account.
+// It concerns a user rather than a team, so it isn't in Types and can't be
+// subscribed to by webhooks.
+const AccountLocked = "account.locked"
+
+// Types lists every team event type that is published.
+var Types = []string{TaskCreated, TaskAssigned, TaskStatusChanged, MemberInvited, MemberJoined, ProjectDeleted}
+
+// Event is one thing that happened. IDs are hex strings and empty when they
+// don't apply; Data carries the type-specific details.
+type Event struct {
+	Type       string                 `json:"type"`
+	ActorID    string                 `json:"actorId"`
+	TeamID     string                 `json:"teamId,omitempty"`
+	ProjectID  string                 `json:"projectId,omitempty"`
+	TaskID     string                 `json:"taskId,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurredAt"`
+}
+
+type Handler func(ctx context.Context, event Event)
+
+// All subscribes a handler to every event type.
+const All = "*"
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string][]Handler{}
+	running  sync.WaitGroup
+)
+
+// Subscribe registers handler for eventType, or for every type with All.
+func Subscribe(eventType string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[eventType] = append(handlers[eventType], handler)
+}
+
+// Publish hands event to its subscribers. Each one runs in its own goroutine
+// with its own timeout, so a slow or failing subscriber never holds up the
+// request that published the event or the other subscribers.
+func Publish(event Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	mu.RLock()
+	subscribers := append(append([]Handler{}, handlers[event.Type]...), handlers[All]...)
+	mu.RUnlock()
+
+	running.Add(len(subscribers))
+	for _, handler := range subscribers {
+		go func(handler Handler) {
+			defer running.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			defer func() {
+				if r := recover(); r != nil {
+					utils.Logger.Error("Event handler panicked", zap.String("event", event.Type), zap.Any("panic", r))
+				}
+			}()
+
+			handler(ctx, event)
+		}(handler)
+	}
+}
+
+// Wait waits until every subscriber that is running has returned.
+func Wait(ctx context.Context) error {
+	return utils.WaitContext(ctx, &running)
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/archive"
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// maxArchiveSize bounds an uploaded team archive.
+const maxArchiveSize = 50 << 20
+
+// archiveTimeout is how long an export or import may take.
+const archiveTimeout = 5 * time.Minute
+
+// extendDeadlines lifts the server's read and write timeouts, which are sized
+// for ordinary requests, to archiveTimeout for this one. Otherwise a large
+// upload is cut off, and so is an export that has already sent its status,
+// leaving the client with a truncated zip.
+func extendDeadlines(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(archiveTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		utils.RequestLogger(r).Warn("Failed to extend read deadline", zap.Error(err))
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		utils.RequestLogger(r).Warn("Failed to extend write deadline", zap.Error(err))
+	}
+}
+
+// ExportTeam streams the team's archive: a zip of NDJSON files with the
+// team, members, projects, sprints, tasks, templates, messages and activity.
+func ExportTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), archiveTimeout)
+	defer cancel()
+
+	var member models.TeamMember
+	err = findAdmin(ctx, r, userID, teamID, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	count, err := database.DB.Collection("teams").CountDocuments(ctx, bson.M{"_id": teamID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding Team", "")
+		return
+	}
+	if count == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Team not found", "")
+		return
+	}
+
+	extendDeadlines(w, r)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="team-`+teamID.Hex()+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+
+	// The status is already sent, so a failure part way leaves the client
+	// with a truncated zip, which won't open.
+	manifest, err := archive.Export(ctx, w, teamID)
+	if err != nil {
+		utils.RequestLogger(r).Error("Failed to export team", zap.Error(err))
+		return
+	}
+
+	utils.Log(ctx, userID, teamID.Hex(), "", "", "Exported Team", userID+" exported team '"+manifest.TeamName+"'")
+	utils.RequestLogger(r).Info("Exported Team")
+}
+
+// ImportTeam creates a team from an archive sent as the request body, with
+// the caller as its only member and admin. Nobody else in the archive is
+// matched to an account here, so uploads can't be used to find out which
+// emails are registered or to send them invites; their tasks are left
+// unassigned and the new admin invites the team again. Operators can import
+// with matching through collabctl.
+func ImportTeam(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	extendDeadlines(w, r)
+
+	// zip needs random access, so the upload is spooled to disk.
+	f, err := os.CreateTemp("", "team-import-*.zip")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error reading archive", "")
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, http.MaxBytesReader(w, r.Body, maxArchiveSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Archive is too large", "")
+		} else {
+			utils.RespondWithError(w, http.StatusBadRequest, "Error reading archive", "")
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), archiveTimeout)
+	defer cancel()
+
+	var user models.User
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding user", "")
+		}
+		return
+	}
+
+	result, err := archive.Import(ctx, f, size, archive.ImportOptions{
+		Owner: user,
+		Name:  r.URL.Query().Get("name"),
+	})
+	if err != nil {
+		if errors.Is(err, archive.ErrInvalidArchive) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		} else {
+			utils.RequestLogger(r).Error("Failed to import team", zap.Error(err))
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error importing team", "")
+		}
+		return
+	}
+
+	utils.Log(ctx, userID, result.TeamID.Hex(), "", "", "Imported Team", userID+" imported team '"+result.TeamName+"'")
+	utils.RequestLogger(r).Info("Imported Team")
+
+	utils.RespondWithJSON(w, http.StatusCreated, "Team imported", map[string]interface{}{
+		"team_id": result.TeamID.Hex(),
+		"name":    result.TeamName,
+		"counts":  result.Counts,
+	})
+}
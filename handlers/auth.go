@@ -3,16 +3,27 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"math"
 	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
+	"github.com/Loboo34/collab-api/metrics"
 	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
 	"github.com/Loboo34/collab-api/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func RegisterUser(w http.ResponseWriter, r *http.Request) {
@@ -27,34 +38,34 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.Logger.Warn("Invalid SJon")
+		utils.RequestLogger(r).Warn("Invalid SJon")
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
 		return
 	}
 
 	hashedPass, err := utils.HashPassword(req.Password)
 	if err != nil {
-		utils.Logger.Warn("Failed to hash Passwordf")
+		utils.RequestLogger(r).Warn("Failed to hash Passwordf")
 		utils.RespondWithError(w, http.StatusBadRequest, "Error Hashing password", "")
 		return
 	}
 
 	newUser := models.User{
-		ID:        primitive.NewObjectID(),
-		FullName:  req.FullName,
-		Email:     req.Email,
-		Password:  hashedPass,
-		CreatedAt: time.Now(),
-		Teams:     []primitive.ObjectID{},
+		FullName: req.FullName,
+		Email:    req.Email,
+		Password: hashedPass,
 	}
-	collection := database.DB.Collection("users")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	_, err = collection.InsertOne(ctx, newUser)
+	err = services.CreateUser(ctx, &newUser)
+	if err == services.ErrEmailTaken {
+		utils.RespondWithError(w, http.StatusConflict, "Email already registered", "")
+		return
+	}
 	if err != nil {
-		utils.Logger.Warn("Failed to Register User")
+		utils.RequestLogger(r).Warn("Failed to Register User")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error while regestering new user", "")
 		return
 	}
@@ -80,28 +91,167 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 
 	collection := database.DB.Collection("users")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
+	attempt, ok := claimLogin(ctx, w, r, req.Email)
+	if !ok {
+		return
+	}
+
 	var user models.User
 	err := collection.FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to login", "")
+		return
+	}
+
+	// Unknown emails and passwordless accounts still pay for a bcrypt
+	// comparison, so response times don't reveal which emails have accounts.
+	valid := false
+	if err == nil && user.Password != "" {
+		valid = utils.ComparePassword(req.Password, user.Password)
+	} else {
+		utils.ComparePassword(req.Password, dummyPasswordHash())
+	}
+	if !valid {
+		utils.RequestLogger(r).Warn("Failed login attempt")
+		recordLoginFailure(r, attempt, user)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid email or password", "")
+		return
+	}
+
+	clearLoginFailures(ctx, r, attempt, req.Email)
+
+	respondWithLogin(ctx, w, user)
+}
+
+// dummyPasswordHash is compared against when the email is unknown.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("not-a-real-password")
+	return hash
+})
+
+// loginAttempt is a login counted against the account and the client.
+type loginAttempt struct {
+	account services.LoginClaim
+	client  services.LoginClaim
+}
+
+// claimLogin counts an attempt against the account and the client before
+// the credential is checked. It responds with 429 and reports false when
+// either has failed too often recently and has to wait.
+func claimLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, email string) (loginAttempt, bool) {
+	now := time.Now()
+	var attempt loginAttempt
+
+	client, wait, err := services.ClaimLoginAttempt(ctx, services.IPLoginKey(utils.ClientIP(r)), services.IPLoginPolicy, now)
 	if err != nil {
-		utils.Logger.Warn("User Not found")
-		utils.RespondWithError(w, http.StatusInternalServerError, "Invalid credentials", "")
+		utils.RequestLogger(r).Warn("Failed to check login attempts: " + err.Error())
+	}
+	attempt.client = client
+
+	if wait <= 0 {
+		attempt.account, wait, err = services.ClaimLoginAttempt(ctx, services.AccountLoginKey(email), services.AccountLoginPolicy, now)
+		if err != nil {
+			utils.RequestLogger(r).Warn("Failed to check login attempts: " + err.Error())
+		}
+		// The client's attempt doesn't happen after all.
+		if wait > 0 {
+			if err := services.ReleaseLoginAttempt(ctx, attempt.client); err != nil {
+				utils.RequestLogger(r).Warn("Failed to release login attempt: " + err.Error())
+			}
+		}
+	}
+
+	if wait <= 0 {
+		return attempt, true
+	}
+
+	metrics.Logins.WithLabelValues("throttled").Inc()
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	utils.RespondWithError(w, http.StatusTooManyRequests, "Too many failed attempts, try again in "+strconv.Itoa(seconds)+" seconds", "")
+	return attempt, false
+}
+
+// recordLoginFailure handles a failed password or 2FA code, which
+// claimLogin has already counted, and tells the owner when their account
+// gets locked. user is the zero value when the email is unknown.
+func recordLoginFailure(r *http.Request, attempt loginAttempt, user models.User) {
+	metrics.Logins.WithLabelValues("failed").Inc()
+
+	if attempt.account.Locks && !user.ID.IsZero() {
+		utils.RequestLogger(r).Warn("Account locked after failed logins", zap.String("userID", user.ID.Hex()))
+		events.Publish(events.Event{
+			Type: events.AccountLocked,
+			Data: map[string]interface{}{
+				"userId":  user.ID.Hex(),
+				"minutes": int(services.AccountLoginPolicy.LockFor.Minutes()),
+			},
+		})
+	}
+}
+
+// clearLoginFailures forgets the account's failures after a successful login
+// and takes back the attempt counted against the client.
+func clearLoginFailures(ctx context.Context, r *http.Request, attempt loginAttempt, email string) {
+	if err := services.ClearLoginFailures(ctx, services.AccountLoginKey(email)); err != nil {
+		utils.RequestLogger(r).Warn("Failed to clear login failures: " + err.Error())
+	}
+	if err := services.ReleaseLoginAttempt(ctx, attempt.client); err != nil {
+		utils.RequestLogger(r).Warn("Failed to release login attempt: " + err.Error())
+	}
+}
+
+// respondWithLogin finishes a login whose first factor has been checked.
+// Users with 2FA get an intermediate token to exchange at /auth/2fa/verify;
+// users who haven't set it up but are in a team that requires it get one
+// that only allows enrolling. Everyone else gets a session.
+func respondWithLogin(ctx context.Context, w http.ResponseWriter, user models.User) {
+	if user.TOTPEnabled {
+		metrics.Logins.WithLabelValues("mfa_required").Inc()
+		token, err := utils.GeneratePurposeJWT(user.ID.Hex(), utils.PurposeMFA, mfaTokenLifetime)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to login", "")
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, "Two-factor code required", map[string]interface{}{
+			"mfaRequired": true,
+			"mfaToken":    token,
+		})
 		return
 	}
 
-	if !utils.ComparePassword(req.Password, user.Password) {
-		utils.Logger.Warn("Incorrect password")
-		utils.RespondWithError(w, http.StatusInternalServerError, "Invalid Password", "")
+	required, err := requires2FA(ctx, user.ID.Hex())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to login", "")
+		return
+	}
+	if required {
+		token, err := utils.GeneratePurposeJWT(user.ID.Hex(), utils.PurposeMFAEnroll, mfaTokenLifetime)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to login", "")
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, "Two-factor enrollment required", map[string]interface{}{
+			"mfaEnrollmentRequired": true,
+			"enrollToken":           token,
+		})
 		return
 	}
 
+	respondWithSession(ctx, w, user, nil)
+}
+
+// respondWithSession issues a session JWT for user. The role claim is the
+// role of their first team membership. extra is merged into the response.
+func respondWithSession(ctx context.Context, w http.ResponseWriter, user models.User, extra map[string]interface{}) {
 	memberCollection := database.DB.Collection("team-members")
 	var member models.TeamMember
 
 	role := ""
-	err = memberCollection.FindOne(ctx, bson.M{"user": user.ID.Hex()}).Decode(&member)
+	err := memberCollection.FindOne(ctx, bson.M{"user": user.ID.Hex()}).Decode(&member)
 	if err == nil {
 		role = member.Role
 	}
@@ -112,13 +262,18 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, "Login Successfull", map[string]interface{}{"token": token, "user": map[string]interface{}{
+	data := map[string]interface{}{"token": token, "user": map[string]interface{}{
 		"id":       user.ID.Hex(),
 		"email":    user.Email,
 		"fullname": user.FullName,
 		"role":     role,
-	}})
+	}}
+	for key, value := range extra {
+		data[key] = value
+	}
 
+	metrics.Logins.WithLabelValues("success").Inc()
+	utils.RespondWithJSON(w, http.StatusOK, "Login Successfull", data)
 }
 
 func Profile(w http.ResponseWriter, r *http.Request) {
@@ -133,13 +288,19 @@ func Profile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
 	userCollection := database.DB.Collection("users")
 	var user models.User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	err = userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	err = userCollection.FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
@@ -149,5 +310,171 @@ func Profile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user.Password = ""
+
 	utils.RespondWithJSON(w, http.StatusOK, "User fetched", map[string]interface{}{"user": user})
 }
+
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	var req struct {
+		FullName *string `json:"fullname"`
+		Email    *string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	update := bson.M{}
+	if req.FullName != nil {
+		name := strings.TrimSpace(*req.FullName)
+		if name == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Full name cannot be empty", "")
+			return
+		}
+		update["fullname"] = name
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userCollection := database.DB.Collection("users")
+
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if _, err := mail.ParseAddress(email); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid email address", "")
+			return
+		}
+
+		count, err := userCollection.CountDocuments(ctx, bson.M{"email": email, "_id": bson.M{"$ne": userObjID}})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error checking email", "")
+			return
+		}
+		if count > 0 {
+			utils.RespondWithError(w, http.StatusConflict, "Email already in use", "")
+			return
+		}
+		update["email"] = email
+		update["emailVerified"] = false
+	}
+
+	if len(update) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Nothing to update", "")
+		return
+	}
+
+	var user models.User
+	err = userCollection.FindOneAndUpdate(ctx, bson.M{"_id": userObjID}, bson.M{"$set": update}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if mongo.IsDuplicateKeyError(err) {
+		utils.RespondWithError(w, http.StatusConflict, "Email already in use", "")
+		return
+	}
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error updating profile", "")
+		}
+		return
+	}
+
+	user.Password = ""
+
+	utils.RequestLogger(r).Info("Profile updated")
+	utils.RespondWithJSON(w, http.StatusOK, "Profile updated", map[string]interface{}{"user": user})
+}
+
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	if len(req.NewPassword) < 8 {
+		utils.RespondWithError(w, http.StatusBadRequest, "New password must be at least 8 characters", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userCollection := database.DB.Collection("users")
+
+	var user models.User
+	err = userCollection.FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding user", "")
+		}
+		return
+	}
+
+	if !utils.ComparePassword(req.CurrentPassword, user.Password) {
+		utils.RequestLogger(r).Warn("Incorrect current password on password change")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect", "")
+		return
+	}
+
+	hashedPass, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error Hashing password", "")
+		return
+	}
+
+	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjID}, bson.M{"$set": bson.M{"password": hashedPass}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating password", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Password changed")
+	utils.RespondWithJSON(w, http.StatusOK, "Password changed", map[string]interface{}{"message": "Password updated successfully"})
+}
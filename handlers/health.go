@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+var shuttingDown atomic.Bool
+
+// MarkShuttingDown makes readiness fail, so load balancers stop sending
+// traffic while in-flight requests drain.
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// Healthz reports that the process is up. It doesn't check dependencies, so
+// a database outage doesn't get the process restarted.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, "ok", map[string]interface{}{"status": "ok"})
+}
+
+// Readyz reports whether the API can serve traffic: it isn't shutting down
+// and Mongo answers a ping.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	if shuttingDown.Load() {
+		utils.RespondWithError(w, http.StatusServiceUnavailable, "Shutting down", map[string]interface{}{"status": "shutting_down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := database.Client.Ping(ctx, nil); err != nil {
+		utils.RequestLogger(r).Warn("Readiness check failed: " + err.Error())
+		utils.RespondWithError(w, http.StatusServiceUnavailable, "Database unavailable", map[string]interface{}{"status": "unavailable"})
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "ready", map[string]interface{}{"status": "ready"})
+}
@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// maxInboundPayload bounds the body an inbound hook accepts.
+const maxInboundPayload = 1 << 20
+
+func hashInboundToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateInboundHook sets up a URL other systems can POST to to create tasks
+// in a project. The URL holds the secret token and is only returned here.
+func CreateInboundHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectID, err := primitive.ObjectIDFromHex(vars["projectId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Project ID", "")
+		return
+	}
+
+	var body struct {
+		Name    string              `json:"name"`
+		Mapping models.FieldMapping `json:"mapping"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name is required", "")
+		return
+	}
+
+	if err := services.ValidateMapping(body.Mapping); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+	err = findAdmin(ctx, r, userID, project.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating token", "")
+		return
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	hook := models.InboundHook{
+		ID:        primitive.NewObjectID(),
+		TeamId:    project.TeamId,
+		ProjectId: projectID,
+		Name:      body.Name,
+		TokenHash: hashInboundToken(token),
+		Mapping:   body.Mapping,
+		Active:    true,
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = database.DB.Collection("inbound-hooks").InsertOne(ctx, hook)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to create inbound hook")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating integration", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		project.TeamId.Hex(),
+		projectID.Hex(),
+		"",
+		"Create Integration",
+		userID+" added inbound integration '"+hook.Name+"'")
+
+	utils.RequestLogger(r).Info("Inbound hook created")
+	utils.RespondWithJSON(w, http.StatusCreated, "Integration created", map[string]interface{}{
+		"integration": hook,
+		"url":         "/hooks/inbound/" + token,
+	})
+}
+
+func GetInboundHooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectID, err := primitive.ObjectIDFromHex(vars["projectId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Project ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+	err = findAdmin(ctx, r, userID, project.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	cursor, err := database.DB.Collection("inbound-hooks").Find(ctx, bson.M{"projectId": projectID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching integrations", "")
+		return
+	}
+
+	hooks := []models.InboundHook{}
+	if err = cursor.All(ctx, &hooks); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding integrations", "")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Integrations retrieved", map[string]interface{}{
+		"integrations": hooks,
+		"count":        len(hooks),
+	})
+}
+
+func DeleteInboundHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only DELETE Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	hookID, err := primitive.ObjectIDFromHex(vars["integrationId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Integration ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	hookCollection := database.DB.Collection("inbound-hooks")
+	var hook models.InboundHook
+
+	err = hookCollection.FindOne(ctx, bson.M{"_id": hookID}).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Integration not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding integration", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+	err = findAdmin(ctx, r, userID, hook.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	if _, err = hookCollection.DeleteOne(ctx, bson.M{"_id": hookID}); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting integration", "")
+		return
+	}
+
+	if _, err = database.DB.Collection("inbound-deliveries").DeleteMany(ctx, bson.M{"hookId": hookID}); err != nil {
+		utils.RequestLogger(r).Warn("Failed to delete inbound deliveries")
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		hook.TeamId.Hex(),
+		hook.ProjectId.Hex(),
+		"",
+		"Delete Integration",
+		userID+" removed inbound integration '"+hook.Name+"'")
+
+	utils.RespondWithJSON(w, http.StatusOK, "Integration deleted", map[string]interface{}{"integrationId": hookID.Hex()})
+}
+
+// ReceiveInboundHook creates a task from a JSON payload posted to an inbound
+// hook URL. It needs no login; the token in the URL is the credential.
+//
+// Repeats carrying the same idempotency key, from the Idempotency-Key header
+// or the hook's mapping, return the task created the first time with 200.
+func ReceiveInboundHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	token := vars["token"]
+
+	var payload interface{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxInboundPayload)).Decode(&payload); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var hook models.InboundHook
+	err := database.DB.Collection("inbound-hooks").FindOne(ctx, bson.M{"tokenHash": hashInboundToken(token), "active": true}).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Integration not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding integration", "")
+		}
+		return
+	}
+
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": hook.ProjectId}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	task := services.MapPayload(hook.Mapping, payload)
+	task.ID = primitive.NewObjectID()
+	task.Status = services.Workflow(project)[0]
+	task.TeamId = project.TeamId
+	task.ProjectId = project.ID
+	task.CreatedBy = hook.CreatedBy
+	task.CreatedAt = time.Now()
+
+	if err := services.ValidateTask(&task); err != nil {
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, err.Error(), "")
+		return
+	}
+
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" && hook.Mapping.IdempotencyKey != "" {
+		key = strings.TrimSpace(services.RenderTemplate(hook.Mapping.IdempotencyKey, payload))
+	}
+
+	deliveryCollection := database.DB.Collection("inbound-deliveries")
+	var delivery models.InboundDelivery
+
+	if key != "" {
+		// Claiming the key first, on a unique index, means two concurrent
+		// repeats can't both get past this point.
+		delivery = models.InboundDelivery{
+			ID:        primitive.NewObjectID(),
+			HookId:    hook.ID,
+			Key:       key,
+			CreatedAt: time.Now(),
+		}
+
+		_, err = deliveryCollection.InsertOne(ctx, delivery)
+		if mongo.IsDuplicateKeyError(err) {
+			var existing models.InboundDelivery
+			if err := deliveryCollection.FindOne(ctx, bson.M{"hookId": hook.ID, "key": key}).Decode(&existing); err != nil || existing.TaskId == nil {
+				// The first delivery is still being processed. If it has been
+				// a while it died half way; drop its claim so a retry goes through.
+				if err == nil && time.Since(existing.CreatedAt) > time.Minute {
+					deliveryCollection.DeleteOne(ctx, bson.M{"_id": existing.ID, "taskId": bson.M{"$exists": false}})
+				}
+				utils.RespondWithError(w, http.StatusConflict, "A delivery with this idempotency key is in progress, retry later", "")
+				return
+			}
+
+			utils.RespondWithJSON(w, http.StatusOK, "Duplicate delivery ignored", map[string]interface{}{
+				"taskId":    existing.TaskId.Hex(),
+				"duplicate": true,
+			})
+			return
+		}
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error recording delivery", "")
+			return
+		}
+	}
+
+	if err = services.CreateTask(ctx, &task); err != nil {
+		if key != "" {
+			// Free the key so the sender's retry can succeed.
+			deliveryCollection.DeleteOne(ctx, bson.M{"_id": delivery.ID})
+		}
+		utils.RequestLogger(r).Warn("Failed to create task from inbound hook")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding task", "")
+		return
+	}
+
+	if key != "" {
+		_, err = deliveryCollection.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{"$set": bson.M{"taskId": task.ID}})
+		if err != nil {
+			utils.RequestLogger(r).Warn("Failed to record inbound delivery task")
+		}
+	}
+
+	utils.Log(
+		ctx,
+		hook.CreatedBy,
+		task.TeamId.Hex(),
+		task.ProjectId.Hex(),
+		task.ID.Hex(),
+		"Create Task",
+		"Integration '"+hook.Name+"' created '"+task.Title+"'")
+
+	events.Publish(events.Event{
+		Type:      events.TaskCreated,
+		ActorID:   hook.CreatedBy,
+		TeamID:    task.TeamId.Hex(),
+		ProjectID: task.ProjectId.Hex(),
+		TaskID:    task.ID.Hex(),
+		Data:      map[string]interface{}{"title": task.Title, "status": task.Status, "priority": task.Priority, "integration": hook.Name},
+	})
+
+	utils.RequestLogger(r).Info("Task created from inbound hook")
+	utils.RespondWithJSON(w, http.StatusCreated, "Task added successfully", map[string]interface{}{"task": task})
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// GetMyTasks lists the tasks assigned to the caller across all of their teams,
+// grouped by team and then project. It takes the same filters as GetTasks.
+func GetMyTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.DB.Collection("team-members").Find(ctx, bson.M{"user": userID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding teams", "")
+		return
+	}
+
+	var memberships []models.TeamMember
+	if err = cursor.All(ctx, &memberships); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding teams", "")
+		return
+	}
+
+	// Tasks in teams the user has left stay assigned to them, but aren't theirs to see.
+	teamIDs := []primitive.ObjectID{}
+	for _, membership := range memberships {
+		teamIDs = append(teamIDs, membership.TeamId)
+	}
+
+	spec := taskListSpec
+	// Most important first; both fields are always set, so cursors stay exact.
+	spec.DefaultSort = "-priority,createdAt"
+
+	query, err := utils.ParseListQuery(r, bson.M{"assigned": userObjID, "teamid": bson.M{"$in": teamIDs}}, spec)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	page, err := utils.FindPage[models.Task](ctx, database.DB.Collection("tasks"), query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching tasks", "")
+		return
+	}
+
+	teamNames := map[primitive.ObjectID]string{}
+	if len(teamIDs) > 0 {
+		cursor, err = database.DB.Collection("teams").Find(ctx, bson.M{"_id": bson.M{"$in": teamIDs}})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding teams", "")
+			return
+		}
+
+		var teams []models.Team
+		if err = cursor.All(ctx, &teams); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding teams", "")
+			return
+		}
+		for _, team := range teams {
+			teamNames[team.ID] = team.Name
+		}
+	}
+
+	projectIDs := []primitive.ObjectID{}
+	for _, task := range page.Items {
+		projectIDs = append(projectIDs, task.ProjectId)
+	}
+
+	projectNames := map[primitive.ObjectID]string{}
+	if len(projectIDs) > 0 {
+		cursor, err = database.DB.Collection("projects").Find(ctx, bson.M{"_id": bson.M{"$in": projectIDs}})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding projects", "")
+			return
+		}
+
+		var projects []models.Project
+		if err = cursor.All(ctx, &projects); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding projects", "")
+			return
+		}
+		for _, project := range projects {
+			projectNames[project.ID] = project.Name
+		}
+	}
+
+	type projectGroup struct {
+		ProjectID string        `json:"projectId"`
+		Name      string        `json:"name"`
+		Tasks     []models.Task `json:"tasks"`
+	}
+	type teamGroup struct {
+		TeamID   string          `json:"teamId"`
+		Name     string          `json:"name"`
+		Projects []*projectGroup `json:"projects"`
+	}
+
+	// Groups keep the order in which their first task appears, so the
+	// requested sort carries through to the grouped output.
+	groups := []*teamGroup{}
+	teamIndex := map[primitive.ObjectID]*teamGroup{}
+	projectIndex := map[primitive.ObjectID]*projectGroup{}
+
+	for _, task := range page.Items {
+		team, ok := teamIndex[task.TeamId]
+		if !ok {
+			team = &teamGroup{TeamID: task.TeamId.Hex(), Name: teamNames[task.TeamId]}
+			teamIndex[task.TeamId] = team
+			groups = append(groups, team)
+		}
+
+		project, ok := projectIndex[task.ProjectId]
+		if !ok {
+			project = &projectGroup{ProjectID: task.ProjectId.Hex(), Name: projectNames[task.ProjectId]}
+			projectIndex[task.ProjectId] = project
+			team.Projects = append(team.Projects, project)
+		}
+
+		project.Tasks = append(project.Tasks, task)
+	}
+
+	utils.SetPageHeaders(w, r, query, page)
+	utils.RequestLogger(r).Info("Fetched assigned tasks")
+	utils.RespondWithJSON(w, http.StatusOK, "Assigned tasks retrieved", map[string]interface{}{
+		"teams":       groups,
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// GetMyTeams lists the caller's teams together with their role in each.
+func GetMyTeams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.DB.Collection("team-members").Find(ctx, bson.M{"user": userID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding teams", "")
+		return
+	}
+
+	var memberships []models.TeamMember
+	if err = cursor.All(ctx, &memberships); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding teams", "")
+		return
+	}
+
+	teamIDs := []primitive.ObjectID{}
+	for _, membership := range memberships {
+		teamIDs = append(teamIDs, membership.TeamId)
+	}
+
+	teams := map[primitive.ObjectID]models.Team{}
+	if len(teamIDs) > 0 {
+		cursor, err = database.DB.Collection("teams").Find(ctx, bson.M{"_id": bson.M{"$in": teamIDs}})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding teams", "")
+			return
+		}
+
+		var found []models.Team
+		if err = cursor.All(ctx, &found); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding teams", "")
+			return
+		}
+		for _, team := range found {
+			teams[team.ID] = team
+		}
+	}
+
+	type myTeam struct {
+		Team     models.Team `json:"team"`
+		Role     string      `json:"role"`
+		JoinedAt time.Time   `json:"joinedAt"`
+	}
+
+	result := []myTeam{}
+	for _, membership := range memberships {
+		team, ok := teams[membership.TeamId]
+		if !ok {
+			continue
+		}
+		result = append(result, myTeam{Team: team, Role: membership.Role, JoinedAt: membership.JoinedAt})
+	}
+
+	utils.RequestLogger(r).Info("Fetched user teams with roles")
+	utils.RespondWithJSON(w, http.StatusOK, "Teams retrieved successfully", map[string]interface{}{
+		"teams": result,
+		"count": len(result),
+	})
+}
@@ -0,0 +1,585 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+const (
+	// mfaTokenLifetime bounds the time between the password and the code.
+	mfaTokenLifetime = 5 * time.Minute
+
+	totpIssuer        = "Collab"
+	recoveryCodeCount = 10
+)
+
+// requires2FA reports whether any of the user's teams requires 2FA.
+func requires2FA(ctx context.Context, userID string) (bool, error) {
+	cursor, err := database.DB.Collection("team-members").Find(ctx, bson.M{"user": userID})
+	if err != nil {
+		return false, err
+	}
+
+	var memberships []models.TeamMember
+	if err = cursor.All(ctx, &memberships); err != nil {
+		return false, err
+	}
+	if len(memberships) == 0 {
+		return false, nil
+	}
+
+	teamIDs := make([]primitive.ObjectID, len(memberships))
+	for i, membership := range memberships {
+		teamIDs[i] = membership.TeamId
+	}
+
+	count, err := database.DB.Collection("teams").CountDocuments(ctx, bson.M{"_id": bson.M{"$in": teamIDs}, "require2FA": true})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRecoveryCodes returns fresh recovery codes formatted for the user and
+// the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused
+// recovery code. A TOTP code is only accepted once and a recovery code is
+// used up; both are claimed with conditional updates so concurrent requests
+// can't reuse them.
+func checkSecondFactor(ctx context.Context, user models.User, code, recoveryCode string, now time.Time) (bool, error) {
+	userCollection := database.DB.Collection("users")
+
+	if recoveryCode != "" {
+		hash := hashRecoveryCode(recoveryCode)
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "recoveryCodes": hash},
+			bson.M{"$pull": bson.M{"recoveryCodes": hash}})
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	counter, ok := utils.ValidateTOTP(user.TOTPSecret, code, now)
+	if !ok {
+		return false, nil
+	}
+
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "$or": bson.A{
+			bson.M{"totpLastCounter": bson.M{"$exists": false}},
+			bson.M{"totpLastCounter": bson.M{"$lt": counter}},
+		}},
+		bson.M{"$set": bson.M{"totpLastCounter": counter}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// findCurrentUser loads the authenticated user, writing the error response
+// itself when that fails.
+func findCurrentUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	var user models.User
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return user, false
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return user, false
+	}
+
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding user", "")
+		}
+		return user, false
+	}
+
+	return user, true
+}
+
+// VerifyMFA is the second step of logging in with 2FA: it exchanges the
+// intermediate token and a TOTP or recovery code for a session.
+func VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	var req struct {
+		MFAToken     string `json:"mfaToken"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	userID, err := utils.ValidatePurposeJWT(req.MFAToken, utils.PurposeMFA)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired login, please sign in again", "")
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user)
+	if err != nil || !user.TOTPEnabled {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired login, please sign in again", "")
+		return
+	}
+
+	// Codes are guessable too, so they count towards the same lockout as
+	// passwords.
+	attempt, allowed := claimLogin(ctx, w, r, user.Email)
+	if !allowed {
+		return
+	}
+
+	ok, err := checkSecondFactor(ctx, user, req.Code, req.RecoveryCode, time.Now())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error checking code", "")
+		return
+	}
+	if !ok {
+		utils.RequestLogger(r).Warn("Invalid two-factor code")
+		recordLoginFailure(r, attempt, user)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid code", "")
+		return
+	}
+
+	clearLoginFailures(ctx, r, attempt, user.Email)
+
+	var extra map[string]interface{}
+	if req.RecoveryCode != "" {
+		extra = map[string]interface{}{"recoveryCodesLeft": len(user.RecoveryCodes) - 1}
+	}
+	respondWithSession(ctx, w, user, extra)
+}
+
+// EnrollTOTP starts setting up 2FA. The secret only takes effect once a code
+// from it is confirmed; enrolling again replaces an unconfirmed secret.
+func EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := findCurrentUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", "")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating secret", "")
+		return
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"totpPendingSecret": secret}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error saving secret", "")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Scan the code with your authenticator app", map[string]interface{}{
+		"secret": secret,
+		"uri":    utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP enables 2FA once the user proves their app produces codes for
+// the pending secret, and returns the recovery codes, shown only here. When
+// called with an enrollment token it also finishes logging in.
+func ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := findCurrentUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", "")
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Start enrollment first", "")
+		return
+	}
+
+	counter, ok := utils.ValidateTOTP(user.TOTPPendingSecret, req.Code, time.Now())
+	if !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid code", "")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating recovery codes", "")
+		return
+	}
+
+	result, err := database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID, "totpPendingSecret": user.TOTPPendingSecret},
+		bson.M{
+			"$set": bson.M{
+				"totpEnabled":     true,
+				"totpSecret":      user.TOTPPendingSecret,
+				"totpLastCounter": counter,
+				"recoveryCodes":   hashes,
+			},
+			"$unset": bson.M{"totpPendingSecret": ""},
+		})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication", "")
+		return
+	}
+	if result.ModifiedCount == 0 {
+		utils.RespondWithError(w, http.StatusConflict, "Enrollment changed, please start again", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Two-factor authentication enabled")
+
+	if r.Context().Value("purpose") == utils.PurposeMFAEnroll {
+		respondWithSession(ctx, w, user, map[string]interface{}{"recoveryCodes": codes})
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Two-factor authentication enabled", map[string]interface{}{
+		"recoveryCodes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. It needs a current
+// TOTP code so a stolen session alone can't mint new ones.
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := findCurrentUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		utils.RespondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled", "")
+		return
+	}
+
+	// A stolen session mustn't get unlimited guesses at the code either.
+	attempt, allowed := claimLogin(ctx, w, r, user.Email)
+	if !allowed {
+		return
+	}
+
+	ok, err := checkSecondFactor(ctx, user, req.Code, "", time.Now())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error checking code", "")
+		return
+	}
+	if !ok {
+		utils.RequestLogger(r).Warn("Invalid two-factor code")
+		recordLoginFailure(r, attempt, user)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid code", "")
+		return
+	}
+
+	clearLoginFailures(ctx, r, attempt, user.Email)
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating recovery codes", "")
+		return
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"recoveryCodes": hashes}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error saving recovery codes", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Recovery codes regenerated")
+	utils.RespondWithJSON(w, http.StatusOK, "Recovery codes regenerated", map[string]interface{}{"recoveryCodes": codes})
+}
+
+// DisableTOTP turns 2FA off with a TOTP or recovery code. It is refused while
+// one of the user's teams requires 2FA.
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only DELETE Allowed", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	var req struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := findCurrentUser(ctx, w, r)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		utils.RespondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled", "")
+		return
+	}
+
+	required, err := requires2FA(ctx, user.ID.Hex())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error checking teams", "")
+		return
+	}
+	if required {
+		utils.RespondWithError(w, http.StatusConflict, "One of your teams requires two-factor authentication", "")
+		return
+	}
+
+	// Wrong codes count towards the lockout, as they do in VerifyMFA.
+	attempt, allowed := claimLogin(ctx, w, r, user.Email)
+	if !allowed {
+		return
+	}
+
+	ok, err = checkSecondFactor(ctx, user, req.Code, req.RecoveryCode, time.Now())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error checking code", "")
+		return
+	}
+	if !ok {
+		utils.RequestLogger(r).Warn("Invalid two-factor code")
+		recordLoginFailure(r, attempt, user)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid code", "")
+		return
+	}
+
+	clearLoginFailures(ctx, r, attempt, user.Email)
+
+	_, err = database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{
+		"totpEnabled":       "",
+		"totpSecret":        "",
+		"totpPendingSecret": "",
+		"totpLastCounter":   "",
+		"recoveryCodes":     "",
+	}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Two-factor authentication disabled")
+	utils.RespondWithJSON(w, http.StatusOK, "Two-factor authentication disabled", map[string]interface{}{"totpEnabled": false})
+}
+
+// SetTeamRequire2FA turns the team's 2FA requirement on or off. Members
+// without 2FA have to enroll the next time they log in; sessions they
+// already have last until they expire.
+func SetTeamRequire2FA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	var req struct {
+		Required *bool `json:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Required == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Set required to true or false", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, teamID, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	result, err := database.DB.Collection("teams").UpdateOne(ctx, bson.M{"_id": teamID}, bson.M{"$set": bson.M{"require2FA": *req.Required}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating team", "")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Team not found", "")
+		return
+	}
+
+	// Tell the admin how many members still have to enroll. Service accounts
+	// authenticate with tokens only and are not affected.
+	cursor, err := memberCollection.Find(ctx, bson.M{"teamId": teamID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching members", "")
+		return
+	}
+	var members []models.TeamMember
+	if err = cursor.All(ctx, &members); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding members", "")
+		return
+	}
+
+	var memberIDs []primitive.ObjectID
+	for _, m := range members {
+		if id, err := primitive.ObjectIDFromHex(m.User); err == nil {
+			memberIDs = append(memberIDs, id)
+		}
+	}
+
+	pending, err := database.DB.Collection("users").CountDocuments(ctx, bson.M{
+		"_id":            bson.M{"$in": memberIDs},
+		"totpEnabled":    bson.M{"$ne": true},
+		"serviceAccount": bson.M{"$ne": true},
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error counting members", "")
+		return
+	}
+
+	action := "no longer requires"
+	if *req.Required {
+		action = "now requires"
+	}
+	utils.Log(
+		ctx,
+		userID,
+		teamID.Hex(),
+		"",
+		"",
+		"Update Team Security",
+		userID+": team "+action+" two-factor authentication")
+
+	utils.RequestLogger(r).Info("Team 2FA requirement updated")
+	utils.RespondWithJSON(w, http.StatusOK, "Team updated", map[string]interface{}{
+		"teamId":            teamID.Hex(),
+		"require2FA":        *req.Required,
+		"membersWithout2FA": pending,
+	})
+}
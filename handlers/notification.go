@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/jobs"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/notifications"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+var notificationListSpec = utils.ListSpec{
+	Sort:        map[string]string{"createdAt": "createdAt"},
+	DefaultSort: "-createdAt",
+	Equals:      map[string]string{"type": "type"},
+}
+
+// GetNotifications lists the caller's notifications, newest first. Pass
+// unread=true to only get unread ones.
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	base := bson.M{"userId": userID}
+	if r.URL.Query().Get("unread") == "true" {
+		base["read"] = false
+	}
+
+	query, err := utils.ParseListQuery(r, base, notificationListSpec)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	page, err := utils.FindPage[models.Notification](ctx, database.DB.Collection("notifications"), query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching notifications", "")
+		return
+	}
+
+	utils.SetPageHeaders(w, r, query, page)
+	utils.RespondWithJSON(w, http.StatusOK, "Notifications retrieved", map[string]interface{}{
+		"notifications": page.Items,
+		"count":         len(page.Items),
+		"total":         page.Total,
+		"next_cursor":   page.NextCursor,
+	})
+}
+
+func GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	count, err := database.DB.Collection("notifications").CountDocuments(ctx, bson.M{"userId": userID, "read": false})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error counting notifications", "")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Unread count retrieved", map[string]interface{}{"unread": count})
+}
+
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	notificationID, err := primitive.ObjectIDFromHex(vars["notificationId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Notification ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Scoping on userId means other users' notifications simply aren't found.
+	result, err := database.DB.Collection("notifications").UpdateOne(
+		ctx,
+		bson.M{"_id": notificationID, "userId": userID, "read": false},
+		bson.M{"$set": bson.M{"read": true, "readAt": time.Now()}},
+	)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating notification", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		count, err := database.DB.Collection("notifications").CountDocuments(ctx, bson.M{"_id": notificationID, "userId": userID})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding notification", "")
+			return
+		}
+		if count == 0 {
+			utils.RespondWithError(w, http.StatusNotFound, "Notification not found", "")
+			return
+		}
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Notification marked as read", map[string]interface{}{
+		"notificationId": notificationID.Hex(),
+	})
+}
+
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := database.DB.Collection("notifications").UpdateMany(
+		ctx,
+		bson.M{"userId": userID, "read": false},
+		bson.M{"$set": bson.M{"read": true, "readAt": time.Now()}},
+	)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating notifications", "")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Notifications marked as read", map[string]interface{}{
+		"updated": result.ModifiedCount,
+	})
+}
+
+// GetNotificationPreferences returns the channel for every event type,
+// including the in-app default for types the user never set.
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding user", "")
+		}
+		return
+	}
+
+	preferences := map[string]string{}
+	for _, eventType := range notifications.Types {
+		preferences[eventType] = notifications.Channel(user, eventType)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Notification preferences retrieved", map[string]interface{}{
+		"preferences": preferences,
+	})
+}
+
+// UpdateNotificationPreferences sets the channel (inapp, email, both or none)
+// for the event types in the body and leaves the others as they are.
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	var body struct {
+		Preferences map[string]string `json:"preferences"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	if len(body.Preferences) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "No preferences given", "")
+		return
+	}
+
+	known := map[string]bool{}
+	for _, eventType := range notifications.Types {
+		known[eventType] = true
+	}
+
+	update := bson.M{}
+	for eventType, channel := range body.Preferences {
+		if !known[eventType] {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown event type "+eventType+". Must be one of: "+strings.Join(notifications.Types, ", "), "")
+			return
+		}
+		if !models.NotificationChannels[channel] {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid channel for "+eventType+". Must be: inapp, email, both or none", "")
+			return
+		}
+		update["notificationPrefs."+notifications.PrefKey(eventType)] = channel
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": userObjID}, bson.M{"$set": update})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating preferences", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Notification preferences updated")
+	utils.RespondWithJSON(w, http.StatusOK, "Notification preferences updated", map[string]interface{}{
+		"preferences": body.Preferences,
+	})
+}
+
+// UpdateDigest opts the caller into a daily or weekly email digest, or out of
+// it with "off". The first digest covers activity from the moment they opt in.
+func UpdateDigest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	var body struct {
+		Frequency string `json:"frequency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON format", "")
+		return
+	}
+
+	var update bson.M
+	switch {
+	case body.Frequency == "off":
+		update = bson.M{"$unset": bson.M{"digest": "", "digestSentAt": ""}}
+	case jobs.DigestPeriods[body.Frequency] > 0:
+		update = bson.M{"$set": bson.M{"digest": body.Frequency}}
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid frequency. Must be: daily, weekly or off", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userCollection := database.DB.Collection("users")
+
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": userObjID}, update)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating digest", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		return
+	}
+
+	// Start the watermark when opting in. Switching between daily and weekly
+	// keeps it, so nothing already sent is repeated and nothing since is skipped.
+	if body.Frequency != "off" {
+		_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjID, "digestSentAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"digestSentAt": time.Now()}})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error updating digest", "")
+			return
+		}
+	}
+
+	utils.RequestLogger(r).Info("Digest preference updated")
+	utils.RespondWithJSON(w, http.StatusOK, "Digest preference updated", map[string]interface{}{
+		"frequency": body.Frequency,
+	})
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/oidc"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// oidcLoginLifetime is how long a user has to finish signing in at the
+// provider. The oidc-logins TTL index cleans up abandoned ones.
+const oidcLoginLifetime = 10 * time.Minute
+
+func GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	names := []string{}
+	for name := range oidc.Providers() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	utils.RespondWithJSON(w, http.StatusOK, "Providers retrieved", map[string]interface{}{"providers": names})
+}
+
+// OIDCLogin starts signing in with a provider: it remembers the state, nonce
+// and PKCE verifier and redirects to the provider's authorization page.
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	provider, ok := oidc.Providers()[vars["provider"]]
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "Provider not found", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	authURL, ok := startOIDCLogin(ctx, w, r, provider, "")
+	if !ok {
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// LinkOIDCIdentity starts linking a provider to the signed-in user. It
+// returns the authorization URL for the client to open; the callback then
+// adds the identity instead of signing in. This is how existing accounts get
+// a provider, since they are only linked by email once it's verified.
+func LinkOIDCIdentity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	provider, ok := oidc.Providers()[vars["provider"]]
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "Provider not found", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	authURL, ok := startOIDCLogin(ctx, w, r, provider, userID)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Continue at the provider", map[string]interface{}{"url": authURL})
+}
+
+// startOIDCLogin remembers the state, nonce and PKCE verifier for a new login
+// and returns the provider's authorization URL. It writes the error response
+// itself and reports false when the request should stop.
+func startOIDCLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID string) (string, bool) {
+	login := models.OIDCLogin{
+		ID:        primitive.NewObjectID(),
+		Provider:  provider.Name,
+		CreatedAt: time.Now(),
+		UserID:    userID,
+	}
+	for _, value := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error starting login", "")
+			return "", false
+		}
+		*value = random
+	}
+
+	authURL, err := provider.AuthURL(ctx, login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		utils.RequestLogger(r).Warn("OIDC discovery failed: " + err.Error())
+		utils.RespondWithError(w, http.StatusBadGateway, "Provider unavailable", "")
+		return "", false
+	}
+
+	_, err = database.DB.Collection("oidc-logins").InsertOne(ctx, login)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error starting login", "")
+		return "", false
+	}
+
+	return authURL, true
+}
+
+// OIDCCallback finishes signing in. The user is found by their linked
+// identity, then by an email both the provider and the account have verified,
+// in which case the identity is linked; otherwise a new user is created. It
+// responds like LoginUser. For a link started by LinkOIDCIdentity it adds the
+// identity to that user instead.
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	provider, ok := oidc.Providers()[vars["provider"]]
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "Provider not found", "")
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Sign in was cancelled or denied", "")
+		return
+	}
+	if query.Get("state") == "" || query.Get("code") == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Missing code or state", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Deleting the login on read makes every state single-use.
+	var login models.OIDCLogin
+	err := database.DB.Collection("oidc-logins").FindOneAndDelete(ctx, bson.M{"state": query.Get("state"), "provider": provider.Name}).Decode(&login)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown or used login state", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding login", "")
+		}
+		return
+	}
+	if time.Since(login.CreatedAt) > oidcLoginLifetime {
+		utils.RespondWithError(w, http.StatusBadRequest, "Login expired, please try again", "")
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, query.Get("code"), login.Verifier, login.Nonce)
+	if err != nil {
+		utils.RequestLogger(r).Warn("OIDC exchange failed: " + err.Error())
+		utils.RespondWithError(w, http.StatusUnauthorized, "Sign in failed", "")
+		return
+	}
+
+	if login.UserID != "" {
+		linkOIDCIdentity(ctx, w, r, provider.Name, claims, login.UserID)
+		return
+	}
+
+	user, err := oidcUser(ctx, provider.Name, claims)
+	if err != nil {
+		if err == errOIDCNoEmail {
+			utils.RespondWithError(w, http.StatusUnauthorized, err.Error(), "")
+		} else if err == errOIDCAccountExists {
+			utils.RespondWithError(w, http.StatusConflict, err.Error(), "")
+		} else {
+			utils.RequestLogger(r).Warn("OIDC user lookup failed: " + err.Error())
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error signing in", "")
+		}
+		return
+	}
+
+	utils.RequestLogger(r).Info("User signed in with " + provider.Name)
+	respondWithLogin(ctx, w, user)
+}
+
+// linkOIDCIdentity finishes a link started by LinkOIDCIdentity.
+func linkOIDCIdentity(ctx context.Context, w http.ResponseWriter, r *http.Request, providerName string, claims *oidc.Claims, userIDStr string) {
+	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	linked, err := oidcUsers.FindByIdentity(ctx, providerName, claims.Subject)
+	if err == nil && linked.ID != userID {
+		utils.RespondWithError(w, http.StatusConflict, "This provider account is already linked to a user", "")
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		identity := models.Identity{Provider: providerName, Subject: claims.Subject, LinkedAt: time.Now()}
+		err = oidcUsers.Link(ctx, userID, identity)
+	}
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			utils.RespondWithError(w, http.StatusConflict, "This provider account is already linked to a user", "")
+		} else if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error linking provider", "")
+		}
+		return
+	}
+
+	utils.RequestLogger(r).Info("User linked " + providerName)
+	utils.RespondWithJSON(w, http.StatusOK, "Provider linked", map[string]interface{}{"provider": providerName})
+}
+
+var (
+	errOIDCNoEmail       = errors.New("The provider did not return a verified email")
+	errOIDCAccountExists = errors.New("An account with this email already exists, sign in and link the provider from your profile")
+)
+
+// oidcUserStore is what oidcUser needs from the users collection. Lookups
+// that find nothing return mongo.ErrNoDocuments.
+type oidcUserStore interface {
+	FindByIdentity(ctx context.Context, provider, subject string) (models.User, error)
+	// LinkByEmail adds identity to the user with email if the account's email
+	// is verified, other than service accounts.
+	LinkByEmail(ctx context.Context, email string, identity models.Identity) (models.User, error)
+	// EmailTaken reports whether a user other than a service account has email.
+	EmailTaken(ctx context.Context, email string) (bool, error)
+	// Link adds identity to the user with userID.
+	Link(ctx context.Context, userID primitive.ObjectID, identity models.Identity) error
+	Create(ctx context.Context, user models.User) error
+}
+
+var oidcUsers oidcUserStore = mongoOIDCUsers{}
+
+// oidcUser returns the user for a verified identity, linking or creating it.
+// Only emails verified on both sides are used to link, so neither a provider
+// account nor a local one registered with someone else's address can take
+// over the other. Accounts with an unverified email have to link from a
+// signed-in session.
+func oidcUser(ctx context.Context, providerName string, claims *oidc.Claims) (models.User, error) {
+	identity := models.Identity{Provider: providerName, Subject: claims.Subject, LinkedAt: time.Now()}
+
+	user, err := oidcUsers.FindByIdentity(ctx, providerName, claims.Subject)
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return user, errOIDCNoEmail
+	}
+
+	user, err = oidcUsers.LinkByEmail(ctx, email, identity)
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	taken, err := oidcUsers.EmailTaken(ctx, email)
+	if err != nil {
+		return user, err
+	}
+	if taken {
+		return user, errOIDCAccountExists
+	}
+
+	user = models.User{
+		ID:            primitive.NewObjectID(),
+		FullName:      claims.Name,
+		Email:         email,
+		EmailVerified: true,
+		Teams:         []primitive.ObjectID{},
+		CreatedAt:     time.Now(),
+		Identities:    []models.Identity{identity},
+	}
+	if user.FullName == "" {
+		user.FullName, _, _ = strings.Cut(email, "@")
+	}
+
+	return user, oidcUsers.Create(ctx, user)
+}
+
+type mongoOIDCUsers struct{}
+
+func (mongoOIDCUsers) FindByIdentity(ctx context.Context, provider, subject string) (models.User, error) {
+	var user models.User
+	err := database.DB.Collection("users").FindOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}).Decode(&user)
+	return user, err
+}
+
+func (mongoOIDCUsers) LinkByEmail(ctx context.Context, email string, identity models.Identity) (models.User, error) {
+	var user models.User
+	err := database.DB.Collection("users").FindOneAndUpdate(ctx,
+		bson.M{"email": email, "emailVerified": true, "serviceAccount": bson.M{"$ne": true}},
+		bson.M{"$push": bson.M{"identities": identity}},
+	).Decode(&user)
+	return user, err
+}
+
+func (mongoOIDCUsers) EmailTaken(ctx context.Context, email string) (bool, error) {
+	count, err := database.DB.Collection("users").CountDocuments(ctx, bson.M{"email": email, "serviceAccount": bson.M{"$ne": true}}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (mongoOIDCUsers) Link(ctx context.Context, userID primitive.ObjectID, identity models.Identity) error {
+	result, err := database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID, "serviceAccount": bson.M{"$ne": true}},
+		bson.M{"$push": bson.M{"identities": identity}},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	return err
+}
+
+func (mongoOIDCUsers) Create(ctx context.Context, user models.User) error {
+	_, err := database.DB.Collection("users").InsertOne(ctx, user)
+	return err
+}
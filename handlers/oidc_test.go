@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/oidc"
+)
+
+// fakeOIDCUsers keeps users in memory with the same matching rules as the
+// Mongo store.
+type fakeOIDCUsers struct {
+	users []models.User
+}
+
+func (f *fakeOIDCUsers) FindByIdentity(ctx context.Context, provider, subject string) (models.User, error) {
+	for _, u := range f.users {
+		for _, id := range u.Identities {
+			if id.Provider == provider && id.Subject == subject {
+				return u, nil
+			}
+		}
+	}
+	return models.User{}, mongo.ErrNoDocuments
+}
+
+func (f *fakeOIDCUsers) LinkByEmail(ctx context.Context, email string, identity models.Identity) (models.User, error) {
+	for i, u := range f.users {
+		if u.Email == email && u.EmailVerified && !u.ServiceAccount {
+			f.users[i].Identities = append(f.users[i].Identities, identity)
+			return u, nil
+		}
+	}
+	return models.User{}, mongo.ErrNoDocuments
+}
+
+func (f *fakeOIDCUsers) EmailTaken(ctx context.Context, email string) (bool, error) {
+	for _, u := range f.users {
+		if u.Email == email && !u.ServiceAccount {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeOIDCUsers) Link(ctx context.Context, userID primitive.ObjectID, identity models.Identity) error {
+	for i, u := range f.users {
+		if u.ID == userID && !u.ServiceAccount {
+			f.users[i].Identities = append(f.users[i].Identities, identity)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (f *fakeOIDCUsers) Create(ctx context.Context, user models.User) error {
+	f.users = append(f.users, user)
+	return nil
+}
+
+func useFakeOIDCUsers(t *testing.T, users ...models.User) *fakeOIDCUsers {
+	fake := &fakeOIDCUsers{users: users}
+	saved := oidcUsers
+	oidcUsers = fake
+	t.Cleanup(func() { oidcUsers = saved })
+	return fake
+}
+
+func TestOIDCUserFindsLinkedIdentity(t *testing.T) {
+	existing := models.User{ID: primitive.NewObjectID(), Email: "old@example.com", Identities: []models.Identity{{Provider: "mock", Subject: "sub-1"}}}
+	fake := useFakeOIDCUsers(t, existing)
+
+	// The provider's email may have changed since the identity was linked.
+	user, err := oidcUser(context.Background(), "mock", &oidc.Claims{Subject: "sub-1", Email: "new@example.com", EmailVerified: true})
+	if err != nil || user.ID != existing.ID {
+		t.Fatalf("oidcUser = %v, %v; want the linked user", user.ID, err)
+	}
+	if len(fake.users) != 1 || len(fake.users[0].Identities) != 1 {
+		t.Errorf("users changed: %+v", fake.users)
+	}
+}
+
+func TestOIDCUserLinksVerifiedEmail(t *testing.T) {
+	existing := models.User{ID: primitive.NewObjectID(), Email: "ada@example.com", EmailVerified: true}
+	fake := useFakeOIDCUsers(t, existing)
+
+	user, err := oidcUser(context.Background(), "mock", &oidc.Claims{Subject: "sub-1", Email: " ada@example.com ", EmailVerified: true})
+	if err != nil || user.ID != existing.ID {
+		t.Fatalf("oidcUser = %v, %v; want the existing user", user.ID, err)
+	}
+	if len(fake.users) != 1 {
+		t.Fatalf("a second user was created")
+	}
+	if ids := fake.users[0].Identities; len(ids) != 1 || ids[0].Provider != "mock" || ids[0].Subject != "sub-1" {
+		t.Errorf("identities = %+v, want the mock identity linked", ids)
+	}
+}
+
+func TestOIDCUserRefusesUnverifiedAccount(t *testing.T) {
+	// Someone registered with Ada's address before she first signed in.
+	squatter := models.User{ID: primitive.NewObjectID(), Email: "ada@example.com", Password: "hash"}
+	fake := useFakeOIDCUsers(t, squatter)
+
+	_, err := oidcUser(context.Background(), "mock", &oidc.Claims{Subject: "sub-1", Email: "ada@example.com", EmailVerified: true})
+	if err != errOIDCAccountExists {
+		t.Fatalf("oidcUser = %v, want errOIDCAccountExists", err)
+	}
+	if len(fake.users) != 1 || len(fake.users[0].Identities) != 0 {
+		t.Errorf("users changed: %+v", fake.users)
+	}
+}
+
+func TestOIDCUserRejectsUnverifiedEmail(t *testing.T) {
+	existing := models.User{ID: primitive.NewObjectID(), Email: "ada@example.com"}
+	fake := useFakeOIDCUsers(t, existing)
+
+	for _, claims := range []*oidc.Claims{
+		{Subject: "sub-1", Email: "ada@example.com", EmailVerified: false},
+		{Subject: "sub-1", EmailVerified: true},
+	} {
+		if _, err := oidcUser(context.Background(), "mock", claims); err != errOIDCNoEmail {
+			t.Errorf("oidcUser(%+v) = %v, want errOIDCNoEmail", claims, err)
+		}
+	}
+	if len(fake.users) != 1 || len(fake.users[0].Identities) != 0 {
+		t.Errorf("users changed: %+v", fake.users)
+	}
+}
+
+func TestOIDCUserSkipsServiceAccounts(t *testing.T) {
+	fake := useFakeOIDCUsers(t, models.User{ID: primitive.NewObjectID(), Email: "bot@example.com", ServiceAccount: true})
+
+	_, err := oidcUser(context.Background(), "mock", &oidc.Claims{Subject: "sub-1", Email: "bot@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.users[0].Identities) != 0 {
+		t.Error("an identity was linked to a service account")
+	}
+}
+
+func TestOIDCUserCreatesUser(t *testing.T) {
+	fake := useFakeOIDCUsers(t)
+
+	user, err := oidcUser(context.Background(), "mock", &oidc.Claims{Subject: "sub-1", Email: "grace@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.users) != 1 || fake.users[0].ID != user.ID {
+		t.Fatalf("users = %+v, want the new user", fake.users)
+	}
+	if user.FullName != "grace" || user.Email != "grace@example.com" || !user.EmailVerified || len(user.Identities) != 1 {
+		t.Errorf("created %+v", user)
+	}
+}
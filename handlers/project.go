@@ -4,15 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
 	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
 	"github.com/Loboo34/collab-api/utils"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func CreateProject(w http.ResponseWriter, r *http.Request) {
@@ -28,15 +32,22 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		StartDate   *time.Time `json:"startDate"`
+		Workflow    []string   `json:"workflow"`
 	}
 
 	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invlaid Json format", "")
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+	if err = services.ValidateWorkflow(request.Workflow); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	vars := mux.Vars(r)
@@ -71,24 +82,19 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 		TeamId:      teamID,
 		CreatedBy:   userID,
 		CreatedAt:   time.Now(),
+		StartDate:   request.StartDate,
+		Workflow:    request.Workflow,
 		Tasks:       []string{},
 	}
 
-	projectCollection := database.DB.Collection("projects")
-	_, err = projectCollection.InsertOne(ctx, project)
-	if err != nil {
-		utils.Logger.Warn("Failed to create project")
+	if err = services.CreateProject(ctx, &project); err != nil {
+		utils.RequestLogger(r).Warn("Failed to create project")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating project", "")
 		return
 	}
 
-	_, err = teamCollection.UpdateOne(ctx, bson.M{"_id": teamID}, bson.M{"$addToSet": bson.M{"projects": project.ID.Hex()}})
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error Adding project to teams", "")
-		return
-	}
-
 	utils.Log(
+		ctx,
 		userID,
 		teamIDStr,
 		project.ID.Hex(),
@@ -96,7 +102,7 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 		"Created Project",
 		userID+"Created '"+project.Name)
 
-	utils.Logger.Info("Project Created Successfuly")
+	utils.RequestLogger(r).Info("Project Created Successfuly")
 	utils.RespondWithJSON(w, http.StatusCreated, "Project created successful", map[string]string{"projectID": project.ID.Hex(), "name": project.Name})
 }
 
@@ -125,7 +131,7 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	projectCollection := database.DB.Collection("projects")
@@ -170,6 +176,7 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	utils.Log(
+		ctx,
 		userID,
 		"",
 		projectIDStr,
@@ -177,7 +184,7 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 		"Update Project",
 		userID+"Updated '"+projectIDStr)
 
-	utils.Logger.Info("Project updated successfuly")
+	utils.RequestLogger(r).Info("Project updated successfuly")
 	utils.RespondWithJSON(w, http.StatusOK, "Project updated", map[string]interface{}{"projectID": project.ID, "name": updates.Name})
 }
 
@@ -206,7 +213,7 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	projectCollection := database.DB.Collection("projects")
@@ -241,7 +248,7 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 	taskCollection := database.DB.Collection("tasks")
 	_, err = taskCollection.DeleteMany(ctx, bson.M{"projectId": projectID})
 	if err != nil {
-		utils.Logger.Warn("Failed to delete project tasks")
+		utils.RequestLogger(r).Warn("Failed to delete project tasks")
 		return
 
 	}
@@ -250,14 +257,15 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 	_, err = teamCollection.UpdateOne(
 		ctx,
 		bson.M{"_id": project.TeamId},
-		bson.M{"$pull": bson.M{"projects": projectIDStr}},
+		bson.M{"$pull": bson.M{"projects": projectID}},
 	)
 	if err != nil {
-		utils.Logger.Warn("Failed to update team's projects array")
+		utils.RequestLogger(r).Warn("Failed to update team's projects array")
 		return
 	}
 
 	utils.Log(
+		ctx,
 		userID,
 		"",
 		projectIDStr,
@@ -265,7 +273,15 @@ func DeleteProject(w http.ResponseWriter, r *http.Request) {
 		"Delete",
 		userID+"Deleted '"+projectIDStr)
 
-	utils.Logger.Info("Project deleted successfuly")
+	events.Publish(events.Event{
+		Type:      events.ProjectDeleted,
+		ActorID:   userID,
+		TeamID:    project.TeamId.Hex(),
+		ProjectID: projectIDStr,
+		Data:      map[string]interface{}{"name": project.Name},
+	})
+
+	utils.RequestLogger(r).Info("Project deleted successfuly")
 	utils.RespondWithError(w, http.StatusOK, "Project deleted", map[string]interface{}{"Project": projectID, "user": userID})
 }
 
@@ -294,7 +310,7 @@ func GetProjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	memberCollection := database.DB.Collection("team-members")
@@ -323,33 +339,31 @@ func GetProjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectCollection := database.DB.Collection("projects")
-	cursor, err := projectCollection.Find(ctx, bson.M{"teamId": teamID})
+	query, err := utils.ParseListQuery(r, bson.M{"teamId": teamID}, utils.ListSpec{
+		Sort:        map[string]string{"name": "name", "createdAt": "createdAt", "startDate": "startDate"},
+		DefaultSort: "createdAt",
+		Equals:      map[string]string{"creator": "createdBy"},
+		Ranges:      map[string]string{"created": "createdAt", "start": "startDate"},
+	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching projets", "")
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
 		return
 	}
-	defer cursor.Close(ctx)
-	var projects []models.Project
-	for cursor.Next(ctx) {
-		var project models.Project
-		if err := cursor.Decode(&project); err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding project", "")
-			return
-		}
-		projects = append(projects, project)
-	}
 
-	if err = cursor.Err(); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Cursor error", "")
+	page, err := utils.FindPage[models.Project](ctx, database.DB.Collection("projects"), query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching projets", "")
 		return
 	}
 
-	utils.Logger.Info("Fetched team projects successfully")
+	utils.SetPageHeaders(w, r, query, page)
+	utils.RequestLogger(r).Info("Fetched team projects successfully")
 	utils.RespondWithJSON(w, http.StatusOK, "Projects retrieved", map[string]interface{}{
-		"team_id":  teamID.Hex(),
-		"projects": projects,
-		"count":    len(projects),
+		"team_id":     teamID.Hex(),
+		"projects":    page.Items,
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
 	})
 
 }
@@ -379,7 +393,7 @@ func GetProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	projectCollection := database.DB.Collection("projects")
@@ -410,7 +424,109 @@ func GetProject(w http.ResponseWriter, r *http.Request) {
 
 	
 
-	utils.Logger.Info("Project fetched successfullyt")
+	utils.RequestLogger(r).Info("Project fetched successfullyt")
 	utils.RespondWithJSON(w, http.StatusOK, "Task fetched", map[string]interface{}{"project": project})
 
 }
+
+func GetBoard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectIDStr := vars["projectId"]
+	if projectIDStr == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Missing Project ID", "")
+		return
+	}
+
+	projectID, err := primitive.ObjectIDFromHex(projectIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Project ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	projectCollection := database.DB.Collection("projects")
+	var project models.Project
+
+	err = projectCollection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = memberCollection.FindOne(ctx, bson.M{"user": userID, "teamId": project.TeamId}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	taskCollection := database.DB.Collection("tasks")
+	opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := taskCollection.Find(ctx, bson.M{"projectId": projectID}, opts)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching tasks", "")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	type column struct {
+		Status string        `json:"status"`
+		Tasks  []models.Task `json:"tasks"`
+	}
+
+	workflow := services.Workflow(project)
+	columns := make([]column, len(workflow))
+	for i, status := range workflow {
+		columns[i] = column{Status: status, Tasks: []models.Task{}}
+	}
+
+	for cursor.Next(ctx) {
+		var task models.Task
+		if err := cursor.Decode(&task); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding task", "")
+			return
+		}
+
+		// Older tasks were saved as "Pending", so columns are matched case-insensitively.
+		for i := range columns {
+			if strings.EqualFold(columns[i].Status, task.Status) {
+				columns[i].Tasks = append(columns[i].Tasks, task)
+				break
+			}
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Cursor error", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Fetched project board")
+	utils.RespondWithJSON(w, http.StatusOK, "Board retrieved", map[string]interface{}{
+		"project_id": projectID.Hex(),
+		"columns":    columns,
+	})
+}
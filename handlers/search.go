@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+type searchResult struct {
+	Type      string  `json:"type"`
+	ID        string  `json:"id"`
+	TeamID    string  `json:"teamId"`
+	ProjectID string  `json:"projectId,omitempty"`
+	Title     string  `json:"title"`
+	Snippet   string  `json:"snippet"`
+	Highlight string  `json:"highlight"`
+	Score     float64 `json:"score"`
+}
+
+// Search runs a text search over tasks, projects and team messages in the
+// caller's teams and returns the hits ranked by relevance.
+//
+// Query params: q (required), type (comma separated task, project, message),
+// teamId and limit.
+func Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Missing search query", "")
+		return
+	}
+
+	types := map[string]bool{"task": true, "project": true, "message": true}
+	if t := query.Get("type"); t != "" {
+		types = map[string]bool{}
+		for _, name := range strings.Split(t, ",") {
+			name = strings.TrimSpace(name)
+			if name != "task" && name != "project" && name != "message" {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid type. Must be: task, project or message", "")
+				return
+			}
+			types[name] = true
+		}
+	}
+
+	limit := 20
+	if l := query.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 100 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Limit must be between 1 and 100", "")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.DB.Collection("team-members").Find(ctx, bson.M{"user": userID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding teams", "")
+		return
+	}
+
+	var memberships []models.TeamMember
+	if err = cursor.All(ctx, &memberships); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding teams", "")
+		return
+	}
+
+	var teamIDs []primitive.ObjectID
+	for _, membership := range memberships {
+		teamIDs = append(teamIDs, membership.TeamId)
+	}
+
+	if teamIDStr := query.Get("teamId"); teamIDStr != "" {
+		teamID, err := primitive.ObjectIDFromHex(teamIDStr)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+			return
+		}
+
+		member := false
+		for _, id := range teamIDs {
+			if id == teamID {
+				member = true
+				break
+			}
+		}
+		if !member {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+			return
+		}
+		teamIDs = []primitive.ObjectID{teamID}
+	}
+
+	results := []searchResult{}
+	if len(teamIDs) == 0 {
+		utils.RespondWithJSON(w, http.StatusOK, "No results", map[string]interface{}{"results": results, "count": 0})
+		return
+	}
+
+	teamHexes := make([]string, len(teamIDs))
+	for i, id := range teamIDs {
+		teamHexes[i] = id.Hex()
+	}
+
+	terms := searchTerms(q)
+	opts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetLimit(int64(limit))
+
+	if types["task"] {
+		cursor, err := database.DB.Collection("tasks").Find(ctx, bson.M{"$text": bson.M{"$search": q}, "teamid": bson.M{"$in": teamIDs}}, opts)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error searching tasks", "")
+			return
+		}
+
+		var hits []struct {
+			models.Task `bson:",inline"`
+			Score       float64 `bson:"score"`
+		}
+		if err = cursor.All(ctx, &hits); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding tasks", "")
+			return
+		}
+
+		for _, hit := range hits {
+			snippet, highlighted := highlight(hit.Title+" — "+hit.Description, terms)
+			results = append(results, searchResult{
+				Type:      "task",
+				ID:        hit.ID.Hex(),
+				TeamID:    hit.TeamId.Hex(),
+				ProjectID: hit.ProjectId.Hex(),
+				Title:     hit.Title,
+				Snippet:   snippet,
+				Highlight: highlighted,
+				Score:     hit.Score,
+			})
+		}
+	}
+
+	if types["project"] {
+		cursor, err := database.DB.Collection("projects").Find(ctx, bson.M{"$text": bson.M{"$search": q}, "teamId": bson.M{"$in": teamIDs}}, opts)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error searching projects", "")
+			return
+		}
+
+		var hits []struct {
+			models.Project `bson:",inline"`
+			Score          float64 `bson:"score"`
+		}
+		if err = cursor.All(ctx, &hits); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding projects", "")
+			return
+		}
+
+		for _, hit := range hits {
+			snippet, highlighted := highlight(hit.Name+" — "+hit.Description, terms)
+			results = append(results, searchResult{
+				Type:      "project",
+				ID:        hit.ID.Hex(),
+				TeamID:    hit.TeamId.Hex(),
+				ProjectID: hit.ID.Hex(),
+				Title:     hit.Name,
+				Snippet:   snippet,
+				Highlight: highlighted,
+				Score:     hit.Score,
+			})
+		}
+	}
+
+	if types["message"] {
+		// Messages store their team as a hex string under "temaid".
+		cursor, err := database.DB.Collection("messages").Find(ctx, bson.M{"$text": bson.M{"$search": q}, "temaid": bson.M{"$in": teamHexes}}, opts)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error searching messages", "")
+			return
+		}
+
+		var hits []struct {
+			models.Message `bson:",inline"`
+			Score          float64 `bson:"score"`
+		}
+		if err = cursor.All(ctx, &hits); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding messages", "")
+			return
+		}
+
+		for _, hit := range hits {
+			snippet, highlighted := highlight(hit.Content, terms)
+			results = append(results, searchResult{
+				Type:      "message",
+				ID:        hit.ID.Hex(),
+				TeamID:    hit.TeamId,
+				Title:     hit.User,
+				Snippet:   snippet,
+				Highlight: highlighted,
+				Score:     hit.Score,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	utils.RequestLogger(r).Info("Search completed")
+	utils.RespondWithJSON(w, http.StatusOK, "Search results", map[string]interface{}{
+		"query":   q,
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// searchTerms splits a text search query into the words worth highlighting,
+// skipping negated terms.
+func searchTerms(q string) []string {
+	var terms []string
+	for _, field := range strings.Fields(strings.ReplaceAll(q, `"`, " ")) {
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+		terms = append(terms, strings.ToLower(field))
+	}
+	return terms
+}
+
+const snippetLength = 160
+
+// highlight returns a plain snippet around the first matching term and an
+// HTML-escaped copy of it with every term wrapped in <mark>.
+func highlight(text string, terms []string) (string, string) {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// A few runes change width when lowered; fall back to exact matching
+		// rather than slicing the two strings at different offsets.
+		lower = text
+	}
+
+	start := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+
+	start -= snippetLength / 4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(text) {
+		end = len(text)
+	}
+
+	// Keep the snippet on rune boundaries.
+	for start > 0 && !utf8Start(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8Start(text[end]) {
+		end++
+	}
+
+	snippet := text[start:end]
+	lowerSnippet := lower[start:end]
+
+	var b strings.Builder
+	for i := 0; i < len(snippet); {
+		matched := 0
+		for _, term := range terms {
+			if term != "" && strings.HasPrefix(lowerSnippet[i:], term) && len(term) > matched {
+				matched = len(term)
+			}
+		}
+
+		if matched > 0 {
+			b.WriteString("<mark>" + html.EscapeString(snippet[i:i+matched]) + "</mark>")
+			i += matched
+			continue
+		}
+
+		b.WriteString(html.EscapeString(snippet[i : i+1]))
+		i++
+	}
+
+	return snippet, b.String()
+}
+
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
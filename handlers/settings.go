@@ -0,0 +1,11 @@
+package handlers
+
+import "github.com/Loboo34/collab-api/config"
+
+// appURL is the frontend's base URL, for links sent in emails.
+var appURL = "http://localhost:3000"
+
+// Configure applies the settings the handlers need.
+func Configure(cfg *config.Config) {
+	appURL = cfg.AppURL
+}
@@ -0,0 +1,712 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+func CreateSprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectIDStr := vars["projectId"]
+	if projectIDStr == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Missing Project ID", "")
+		return
+	}
+
+	projectID, err := primitive.ObjectIDFromHex(projectIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Project ID", "")
+		return
+	}
+
+	var request struct {
+		Name      string    `json:"name"`
+		Goal      string    `json:"goal"`
+		StartDate time.Time `json:"startDate"`
+		EndDate   time.Time `json:"endDate"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	if request.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Sprint name is required", "")
+		return
+	}
+
+	if request.StartDate.IsZero() || request.EndDate.IsZero() || !request.EndDate.After(request.StartDate) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Sprint end date must be after its start date", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	projectCollection := database.DB.Collection("projects")
+	var project models.Project
+
+	err = projectCollection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, project.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusForbidden, "Only team admins can manage sprints", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	sprint := models.Sprint{
+		ID:        primitive.NewObjectID(),
+		ProjectId: projectID,
+		TeamId:    project.TeamId,
+		Name:      request.Name,
+		Goal:      request.Goal,
+		StartDate: request.StartDate,
+		EndDate:   request.EndDate,
+		State:     "planned",
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+
+	sprintCollection := database.DB.Collection("sprints")
+	_, err = sprintCollection.InsertOne(ctx, sprint)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to create sprint")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating sprint", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		project.TeamId.Hex(),
+		projectIDStr,
+		"",
+		"Create Sprint",
+		userID+" created sprint '"+sprint.Name+"'")
+
+	utils.RequestLogger(r).Info("Sprint created successfully")
+	utils.RespondWithJSON(w, http.StatusCreated, "Sprint created", map[string]interface{}{"sprint": sprint})
+}
+
+func GetSprints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectID, err := primitive.ObjectIDFromHex(vars["projectId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Project ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	projectCollection := database.DB.Collection("projects")
+	var project models.Project
+
+	err = projectCollection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = memberCollection.FindOne(ctx, bson.M{"user": userID, "teamId": project.TeamId}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	sprintCollection := database.DB.Collection("sprints")
+	opts := options.Find().SetSort(bson.D{{Key: "startDate", Value: 1}})
+	cursor, err := sprintCollection.Find(ctx, bson.M{"projectId": projectID}, opts)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching sprints", "")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	sprints := []models.Sprint{}
+	if err = cursor.All(ctx, &sprints); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding sprints", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Fetched project sprints")
+	utils.RespondWithJSON(w, http.StatusOK, "Sprints retrieved", map[string]interface{}{
+		"project_id": projectID.Hex(),
+		"sprints":    sprints,
+		"count":      len(sprints),
+	})
+}
+
+func UpdateSprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	sprintIDStr := vars["sprintId"]
+	sprintID, err := primitive.ObjectIDFromHex(sprintIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Sprint ID", "")
+		return
+	}
+
+	var request struct {
+		Name      string    `json:"name"`
+		Goal      string    `json:"goal"`
+		StartDate time.Time `json:"startDate"`
+		EndDate   time.Time `json:"endDate"`
+		State     string    `json:"state"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	sprintCollection := database.DB.Collection("sprints")
+	var sprint models.Sprint
+
+	err = sprintCollection.FindOne(ctx, bson.M{"_id": sprintID}).Decode(&sprint)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Sprint not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding sprint", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, sprint.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusForbidden, "Only team admins can manage sprints", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	if sprint.State == "closed" {
+		utils.RespondWithError(w, http.StatusConflict, "Closed sprints cannot be changed", "")
+		return
+	}
+
+	set := bson.M{}
+	if request.Name != "" {
+		set["name"] = request.Name
+		sprint.Name = request.Name
+	}
+	if request.Goal != "" {
+		set["goal"] = request.Goal
+		sprint.Goal = request.Goal
+	}
+	if !request.StartDate.IsZero() {
+		set["startDate"] = request.StartDate
+		sprint.StartDate = request.StartDate
+	}
+	if !request.EndDate.IsZero() {
+		set["endDate"] = request.EndDate
+		sprint.EndDate = request.EndDate
+	}
+
+	if !sprint.EndDate.After(sprint.StartDate) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Sprint end date must be after its start date", "")
+		return
+	}
+
+	switch request.State {
+	case "", sprint.State:
+	case "planned":
+		set["state"] = "planned"
+		sprint.State = "planned"
+	case "active":
+		count, err := sprintCollection.CountDocuments(ctx, bson.M{"projectId": sprint.ProjectId, "state": "active"})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error checking active sprints", "")
+			return
+		}
+		if count > 0 {
+			utils.RespondWithError(w, http.StatusConflict, "Project already has an active sprint", "")
+			return
+		}
+		set["state"] = "active"
+		sprint.State = "active"
+	case "closed":
+		utils.RespondWithError(w, http.StatusBadRequest, "Use the close endpoint to close a sprint", "")
+		return
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid state. Must be: planned or active", "")
+		return
+	}
+
+	if len(set) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Nothing to update", "")
+		return
+	}
+
+	result, err := sprintCollection.UpdateOne(ctx, bson.M{"_id": sprintID}, bson.M{"$set": set})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating sprint", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Sprint not found", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		sprint.TeamId.Hex(),
+		sprint.ProjectId.Hex(),
+		"",
+		"Update Sprint",
+		userID+" updated sprint '"+sprintIDStr+"'")
+
+	utils.RequestLogger(r).Info("Sprint updated")
+	utils.RespondWithJSON(w, http.StatusOK, "Sprint updated", map[string]interface{}{"sprint": sprint})
+}
+
+// CloseSprint closes a sprint and carries its unfinished tasks over to the
+// sprint given in the body, the next planned sprint, or the backlog.
+func CloseSprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	sprintIDStr := vars["sprintId"]
+	sprintID, err := primitive.ObjectIDFromHex(sprintIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Sprint ID", "")
+		return
+	}
+
+	var body struct {
+		NextSprintID string `json:"nextSprintId"`
+		ToBacklog    bool   `json:"toBacklog"`
+	}
+	if r.ContentLength != 0 {
+		if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	sprintCollection := database.DB.Collection("sprints")
+	var sprint models.Sprint
+
+	err = sprintCollection.FindOne(ctx, bson.M{"_id": sprintID}).Decode(&sprint)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Sprint not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding sprint", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, sprint.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusForbidden, "Only team admins can manage sprints", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	if sprint.State == "closed" {
+		utils.RespondWithError(w, http.StatusConflict, "Sprint already closed", "")
+		return
+	}
+
+	var next *models.Sprint
+	switch {
+	case body.ToBacklog:
+	case body.NextSprintID != "":
+		nextID, err := primitive.ObjectIDFromHex(body.NextSprintID)
+		if err != nil || nextID == sprintID {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid next Sprint ID", "")
+			return
+		}
+
+		var candidate models.Sprint
+		err = sprintCollection.FindOne(ctx, bson.M{"_id": nextID, "projectId": sprint.ProjectId, "state": bson.M{"$ne": "closed"}}).Decode(&candidate)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				utils.RespondWithError(w, http.StatusNotFound, "Next sprint not found", "")
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Error finding next sprint", "")
+			}
+			return
+		}
+		next = &candidate
+	default:
+		opts := options.FindOne().SetSort(bson.D{{Key: "startDate", Value: 1}})
+
+		var candidate models.Sprint
+		err = sprintCollection.FindOne(ctx, bson.M{"projectId": sprint.ProjectId, "state": "planned", "_id": bson.M{"$ne": sprintID}}, opts).Decode(&candidate)
+		if err == nil {
+			next = &candidate
+		} else if err != mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding next sprint", "")
+			return
+		}
+	}
+
+	carryOver := bson.M{"$unset": bson.M{"sprintId": ""}}
+	destination := "backlog"
+	if next != nil {
+		carryOver = bson.M{"$set": bson.M{"sprintId": next.ID}}
+		destination = next.ID.Hex()
+	}
+
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": sprint.ProjectId}).Decode(&project)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		return
+	}
+
+	// Unfinished tasks are carried over before the sprint is marked closed, so
+	// a close that fails part way can simply be retried.
+	taskCollection := database.DB.Collection("tasks")
+	moved, err := taskCollection.UpdateMany(ctx, bson.M{"sprintId": sprintID, "status": bson.M{"$ne": services.DoneStatus(project)}}, carryOver)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to carry over unfinished sprint tasks")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error moving unfinished tasks", "")
+		return
+	}
+
+	now := time.Now()
+	result, err := sprintCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": sprintID, "state": bson.M{"$ne": "closed"}},
+		bson.M{"$set": bson.M{"state": "closed", "closedAt": now}},
+	)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error closing sprint", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusConflict, "Sprint already closed", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		sprint.TeamId.Hex(),
+		sprint.ProjectId.Hex(),
+		"",
+		"Close Sprint",
+		userID+" closed sprint '"+sprintIDStr+"', unfinished tasks moved to "+destination)
+
+	utils.RequestLogger(r).Info("Sprint closed")
+	utils.RespondWithJSON(w, http.StatusOK, "Sprint closed", map[string]interface{}{
+		"sprint_id":   sprintIDStr,
+		"moved_tasks": moved.ModifiedCount,
+		"moved_to":    destination,
+	})
+}
+
+// GetSprint returns a sprint with its task scope and completion stats.
+func GetSprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	sprintID, err := primitive.ObjectIDFromHex(vars["sprintId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Sprint ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	sprintCollection := database.DB.Collection("sprints")
+	var sprint models.Sprint
+
+	err = sprintCollection.FindOne(ctx, bson.M{"_id": sprintID}).Decode(&sprint)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Sprint not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding sprint", "")
+		}
+		return
+	}
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = memberCollection.FindOne(ctx, bson.M{"user": userID, "teamId": sprint.TeamId}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	taskCollection := database.DB.Collection("tasks")
+	opts := options.Find().SetSort(bson.D{{Key: "status", Value: 1}, {Key: "position", Value: 1}})
+	cursor, err := taskCollection.Find(ctx, bson.M{"sprintId": sprintID}, opts)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching sprint tasks", "")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	tasks := []models.Task{}
+	if err = cursor.All(ctx, &tasks); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding sprint tasks", "")
+		return
+	}
+
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": sprint.ProjectId}).Decode(&project)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		return
+	}
+
+	doneStatus := services.DoneStatus(project)
+	byStatus := map[string]int{}
+	done := 0
+	for _, task := range tasks {
+		byStatus[task.Status]++
+		if task.Status == doneStatus {
+			done++
+		}
+	}
+
+	completion := 0.0
+	if len(tasks) > 0 {
+		completion = float64(done) / float64(len(tasks)) * 100
+	}
+
+	utils.RequestLogger(r).Info("Fetched sprint")
+	utils.RespondWithJSON(w, http.StatusOK, "Sprint retrieved", map[string]interface{}{
+		"sprint": sprint,
+		"tasks":  tasks,
+		"stats": map[string]interface{}{
+			"total":      len(tasks),
+			"done":       done,
+			"remaining":  len(tasks) - done,
+			"by_status":  byStatus,
+			"completion": completion,
+		},
+	})
+}
+
+// AssignSprint moves a task into a sprint, or back to the backlog when sprintId is empty.
+func AssignSprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	taskIDStr := vars["taskId"]
+	taskID, err := primitive.ObjectIDFromHex(taskIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Task ID", "")
+		return
+	}
+
+	var body struct {
+		SprintID string `json:"sprintId"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	taskCollection := database.DB.Collection("tasks")
+	var task models.Task
+
+	err = taskCollection.FindOne(ctx, bson.M{"_id": taskID}).Decode(&task)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding task", "")
+		}
+		return
+	}
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = memberCollection.FindOne(ctx, bson.M{"user": userID, "teamId": task.TeamId}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"sprintId": ""}}
+	destination := "backlog"
+
+	if body.SprintID != "" {
+		sprintID, err := primitive.ObjectIDFromHex(body.SprintID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid Sprint ID", "")
+			return
+		}
+
+		var sprint models.Sprint
+		err = database.DB.Collection("sprints").FindOne(ctx, bson.M{"_id": sprintID, "projectId": task.ProjectId}).Decode(&sprint)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				utils.RespondWithError(w, http.StatusNotFound, "Sprint not found in task's project", "")
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Error finding sprint", "")
+			}
+			return
+		}
+
+		if sprint.State == "closed" {
+			utils.RespondWithError(w, http.StatusConflict, "Cannot add tasks to a closed sprint", "")
+			return
+		}
+
+		update = bson.M{"$set": bson.M{"sprintId": sprintID}}
+		destination = body.SprintID
+	}
+
+	result, err := taskCollection.UpdateOne(ctx, bson.M{"_id": taskID}, update)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating task sprint", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Task not found", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		task.TeamId.Hex(),
+		task.ProjectId.Hex(),
+		taskIDStr,
+		"Assign Sprint",
+		userID+" moved task '"+taskIDStr+"' to "+destination)
+
+	utils.RequestLogger(r).Info("Task sprint updated")
+	utils.RespondWithJSON(w, http.StatusOK, "Task sprint updated", map[string]interface{}{
+		"taskID":   taskIDStr,
+		"sprintId": body.SprintID,
+	})
+}
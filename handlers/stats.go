@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// GetProjectStats returns the dashboard numbers for one project.
+//
+// Query params: weeks (throughput window, default 12), days (burndown window,
+// default 30) and sprintId, which scopes the burndown to that sprint's dates
+// and tasks instead.
+func GetProjectStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectID, err := primitive.ObjectIDFromHex(vars["projectId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Project ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+	err = database.DB.Collection("team-members").FindOne(ctx, bson.M{"user": userID, "teamId": project.TeamId}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	now := time.Now()
+	scope, ok := statsWindow(w, r, now)
+	if !ok {
+		return
+	}
+
+	scope.Tasks = bson.M{"projectId": projectID}
+	scope.Activity = bson.M{"projectID": projectID.Hex()}
+	scope.DoneStatuses = []string{services.DoneStatus(project)}
+
+	if sprintIDStr := r.URL.Query().Get("sprintId"); sprintIDStr != "" {
+		sprintID, err := primitive.ObjectIDFromHex(sprintIDStr)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid Sprint ID", "")
+			return
+		}
+
+		var sprint models.Sprint
+		err = database.DB.Collection("sprints").FindOne(ctx, bson.M{"_id": sprintID, "projectId": projectID}).Decode(&sprint)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				utils.RespondWithError(w, http.StatusNotFound, "Sprint not found", "")
+			} else {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Error finding sprint", "")
+			}
+			return
+		}
+
+		scope.BurndownTasks = bson.M{"projectId": projectID, "sprintId": sprintID}
+		scope.BurndownFrom = sprint.StartDate
+		if sprint.EndDate.Before(now) {
+			scope.BurndownTo = sprint.EndDate
+		}
+	}
+
+	stats, err := services.Stats(ctx, scope, now)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to compute project stats")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error computing stats", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Fetched project stats")
+	utils.RespondWithJSON(w, http.StatusOK, "Project stats retrieved", map[string]interface{}{
+		"projectId": projectID.Hex(),
+		"stats":     stats,
+	})
+}
+
+// GetTeamStats returns the dashboard numbers across every project in a team.
+// It takes the same weeks and days params as GetProjectStats.
+func GetTeamStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var member models.TeamMember
+	err = database.DB.Collection("team-members").FindOne(ctx, bson.M{"user": userID, "teamId": teamID}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	cursor, err := database.DB.Collection("projects").Find(ctx, bson.M{"teamId": teamID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding projects", "")
+		return
+	}
+
+	var projects []models.Project
+	if err = cursor.All(ctx, &projects); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding projects", "")
+		return
+	}
+
+	// Projects can name their done column differently; a task counts as done
+	// when it sits in any of them.
+	doneStatuses := []string{services.DoneStatus(models.Project{})}
+	for _, project := range projects {
+		doneStatuses = append(doneStatuses, services.DoneStatus(project))
+	}
+
+	now := time.Now()
+	scope, ok := statsWindow(w, r, now)
+	if !ok {
+		return
+	}
+
+	scope.Tasks = bson.M{"teamid": teamID}
+	scope.Activity = bson.M{"teamID": teamID.Hex()}
+	scope.DoneStatuses = doneStatuses
+
+	stats, err := services.Stats(ctx, scope, now)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to compute team stats")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error computing stats", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Fetched team stats")
+	utils.RespondWithJSON(w, http.StatusOK, "Team stats retrieved", map[string]interface{}{
+		"teamId":   teamID.Hex(),
+		"projects": len(projects),
+		"stats":    stats,
+	})
+}
+
+// statsWindow reads the weeks and days params into a scope ending at now.
+// It writes the error response itself and reports false when the request should stop.
+func statsWindow(w http.ResponseWriter, r *http.Request, now time.Time) (services.StatsScope, bool) {
+	query := r.URL.Query()
+	weeks, days := 12, 30
+
+	if value := query.Get("weeks"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 104 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Weeks must be between 1 and 104", "")
+			return services.StatsScope{}, false
+		}
+		weeks = n
+	}
+
+	if value := query.Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 365 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Days must be between 1 and 365", "")
+			return services.StatsScope{}, false
+		}
+		days = n
+	}
+
+	return services.StatsScope{
+		Weeks:        weeks,
+		BurndownFrom: now.AddDate(0, 0, 1-days),
+		BurndownTo:   now,
+	}, true
+}
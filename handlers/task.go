@@ -13,7 +13,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
 	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
 	"github.com/Loboo34/collab-api/utils"
 )
 
@@ -30,10 +32,16 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var request struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		TeamID      string `json:"teamId"`
-		ProjectID   string `json:"projectId"`
+		Title       string             `json:"title"`
+		Description string             `json:"description"`
+		TeamID      string             `json:"teamId"`
+		ProjectID   string             `json:"projectId"`
+		DueDate     *time.Time         `json:"dueDate"`
+		Checklist   []string           `json:"checklist"`
+		Labels      []string           `json:"labels"`
+		Priority    string             `json:"priority"`
+		RRule       string             `json:"rrule"`
+		Recurrence  *models.Recurrence `json:"recurrence"`
 	}
 
 	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -41,13 +49,19 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recurrence, err := parseRecurrence(request.RRule, request.Recurrence, request.DueDate)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
 	teamID, err := primitive.ObjectIDFromHex(request.TeamID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	var team models.Team
@@ -85,29 +99,38 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 		ID:          primitive.NewObjectID(),
 		Title:       request.Title,
 		Description: request.Description,
-		Status:      "Pending",
+		Status:      services.Workflow(project)[0],
 		TeamId:      teamID,
 		ProjectId:   projectID,
+		DueDate:     request.DueDate,
+		Labels:      request.Labels,
+		Priority:    request.Priority,
 		CreatedBy:   userID,
 		CreatedAt:   time.Now(),
 	}
 
-	taskCollection := database.DB.Collection("tasks")
+	for _, item := range request.Checklist {
+		task.Checklist = append(task.Checklist, models.ChecklistItem{Text: item})
+	}
 
-	_, err = taskCollection.InsertOne(ctx, task)
-	if err != nil {
-		utils.Logger.Warn("Failed to Add Task")
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding task", "")
+	if err = services.ValidateTask(&task); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
 		return
 	}
 
-	_, err = projectCollection.UpdateOne(ctx, bson.M{"_id": projectID}, bson.M{"$addToSet": bson.M{"tasks": task.ID}})
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding task to project", "")
+	if recurrence != nil {
+		task.Recurrence = recurrence
+		task.NextRunAt = firstRun(*recurrence, task.CreatedAt)
+	}
+
+	if err = services.CreateTask(ctx, &task); err != nil {
+		utils.RequestLogger(r).Warn("Failed to Add Task")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding task", "")
 		return
 	}
 
 		utils.Log(
+		ctx,
 		userID,
 		"",
 		"",
@@ -115,7 +138,16 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 		"Create Task",
 		userID+"Created '"+task.Title)
 
-	utils.Logger.Info("Task created successfully")
+	events.Publish(events.Event{
+		Type:      events.TaskCreated,
+		ActorID:   userID,
+		TeamID:    task.TeamId.Hex(),
+		ProjectID: task.ProjectId.Hex(),
+		TaskID:    task.ID.Hex(),
+		Data:      map[string]interface{}{"title": task.Title, "status": task.Status, "priority": task.Priority},
+	})
+
+	utils.RequestLogger(r).Info("Task created successfully")
 	utils.RespondWithJSON(w, http.StatusCreated, "Task added successfully", map[string]interface{}{"user": userID, "task": task})
 }
 
@@ -146,21 +178,23 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 
 	taskID, err := primitive.ObjectIDFromHex(taskIDStr)
 	if err != nil {
-		utils.Logger.Warn("Invalid id")
+		utils.RequestLogger(r).Warn("Invalid id")
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Task ID ", "")
 		return
 	}
 
 	var updates struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
+		Title       string    `json:"title"`
+		Description string    `json:"description"`
+		Labels      *[]string `json:"labels"`
+		Priority    *string   `json:"priority"`
 	}
 	if err = json.NewDecoder(r.Body).Decode(&updates); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "invalid json format", "")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	taskCollection := database.DB.Collection("tasks")
@@ -175,12 +209,22 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"title":       updates.Title,
-			"description": updates.Description,
-		},
+	set := bson.M{
+		"title":       updates.Title,
+		"description": updates.Description,
+	}
+	if updates.Labels != nil {
+		set["labels"] = *updates.Labels
+	}
+	if updates.Priority != nil {
+		if *updates.Priority != "" && models.TaskPriorities[*updates.Priority] == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid priority. Must be: low, medium, high or urgent", "")
+			return
+		}
+		set["priority"] = *updates.Priority
+		set["priorityRank"] = models.TaskPriorities[*updates.Priority]
 	}
+	update := bson.M{"$set": set}
 
 	memberCollection := database.DB.Collection("team-members")
 	var member models.TeamMember
@@ -202,18 +246,19 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 
 	result, err := taskCollection.UpdateOne(ctx, bson.M{"_id": taskID}, update)
 	if err != nil {
-		utils.Logger.Warn("Failed to update task")
+		utils.RequestLogger(r).Warn("Failed to update task")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating Task", "")
 		return
 	}
 
 	if result.MatchedCount == 0 {
-		utils.Logger.Warn("Failed to find task")
+		utils.RequestLogger(r).Warn("Failed to find task")
 		utils.RespondWithError(w, http.StatusNotFound, "Task not found", "")
 		return
 	}
 
 		utils.Log(
+		ctx,
 		userID,
 		"",
 		"",
@@ -221,7 +266,7 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 		"Update Task",
 		userID+"Updated task:'"+taskIDStr)
 
-	utils.Logger.Info("Task updated")
+	utils.RequestLogger(r).Info("Task updated")
 	utils.RespondWithJSON(w, http.StatusOK, "Update successful", map[string]interface{}{"taskID": task})
 }
 
@@ -250,7 +295,7 @@ func AssignTo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	taskCollection := database.DB.Collection("tasks")
@@ -306,16 +351,27 @@ func AssignTo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-		utils.Log(
-		userID,
-		"",
-		"",
-		taskIDStr,
-		"Assign Task",
-		userID+"Assigned task: '"+taskIDStr+"to"+body.AssignedTo)
+	utils.LogActivity(ctx, models.ActivityLog{
+		UserID:       userID,
+		TeamID:       task.TeamId.Hex(),
+		ProjectID:    task.ProjectId.Hex(),
+		TaskID:       taskIDStr,
+		Action:       "Assign Task",
+		Message:      userID + " assigned '" + task.Title + "' to " + body.AssignedTo,
+		TargetUserID: body.AssignedTo,
+	})
+
+	events.Publish(events.Event{
+		Type:      events.TaskAssigned,
+		ActorID:   userID,
+		TeamID:    task.TeamId.Hex(),
+		ProjectID: task.ProjectId.Hex(),
+		TaskID:    taskIDStr,
+		Data:      map[string]interface{}{"title": task.Title, "assigneeId": body.AssignedTo},
+	})
 
 
-	utils.Logger.Info("Tasked assigned successfully")
+	utils.RequestLogger(r).Info("Tasked assigned successfully")
 	utils.RespondWithError(w, http.StatusOK, "Task assigned successfully", map[string]interface{}{
 		"taskID":     taskID.Hex(),
 		"assignedTo": body.AssignedTo,
@@ -356,13 +412,7 @@ func Status(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	validStatuses := map[string]bool{"pending": true, "inProgress": true, "done": true}
-	if !validStatuses[body.Status] {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid status. Must be: pending, inProgress, or done", "")
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	taskCollection := database.DB.Collection("tasks")
@@ -378,6 +428,18 @@ func Status(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": task.ProjectId}).Decode(&project)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		return
+	}
+
+	if !services.ValidStatus(project, body.Status) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid status. Must be one of: "+strings.Join(services.Workflow(project), ", "), "")
+		return
+	}
+
 	teamMemberCollection := database.DB.Collection("team-members")
 	var member models.TeamMember
 
@@ -401,7 +463,23 @@ func Status(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := taskCollection.UpdateOne(ctx, bson.M{"_id": taskID}, bson.M{"$set": bson.M{"status": body.Status}})
+	if task.Status == body.Status {
+		utils.RespondWithJSON(w, http.StatusOK, "Status unchanged", map[string]interface{}{
+			"taskID": taskIDStr,
+			"status": body.Status,
+		})
+		return
+	}
+
+	position, err := services.NextPosition(ctx, task.ProjectId, body.Status)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error positioning task", "")
+		return
+	}
+
+	update, done := services.StatusUpdate(project, body.Status, position, time.Now())
+
+	result, err := taskCollection.UpdateOne(ctx, bson.M{"_id": taskID}, update)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating task status", "")
 		return
@@ -411,16 +489,11 @@ func Status(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusNotFound, "Error finding task", "")
 		return
 	}
-	utils.Log(
-		userID,
-		"",
-		"",
-		taskIDStr,
-		"Update status",
-		userID+"updated '"+taskIDStr+"status to'"+body.Status)
+	utils.LogStatusChange(ctx, userID, task, task.Status, body.Status, done)
+	publishStatusChange(userID, task, body.Status)
 
 
-	utils.Logger.Info("Task Status Updated Successfuly")
+	utils.RequestLogger(r).Info("Task Status Updated Successfuly")
 	utils.RespondWithJSON(w, http.StatusOK, "Status Update successfully", map[string]interface{}{
 		"taskID": taskIDStr,
 		"status": body.Status,
@@ -454,7 +527,7 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) {
 	}
 	taskID, _ := primitive.ObjectIDFromHex(taskIDStr)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	taskCollection := database.DB.Collection("tasks")
@@ -486,6 +559,7 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	utils.Log(
+		ctx,
 		userID,
 		"",
 		"",
@@ -493,7 +567,7 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) {
 		"Delete Task",
 		userID+"Deleted '"+taskIDStr)
 
-	utils.Logger.Info("Task deleted successfuly")
+	utils.RequestLogger(r).Info("Task deleted successfuly")
 	utils.RespondWithJSON(w, http.StatusOK, "Task Deleted", "")
 }
 
@@ -518,7 +592,7 @@ func GetTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	memberCollection := database.DB.Collection("team-members")
@@ -534,38 +608,47 @@ func GetTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	taskCollection := database.DB.Collection("tasks")
-	cursor, err := taskCollection.Find(ctx, bson.M{"projectId": projectID})
+	query, err := utils.ParseListQuery(r, bson.M{"projectId": projectID}, taskListSpec)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching tasks", "")
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
 		return
 	}
 
-	defer cursor.Close(ctx)
-
-	var tasks []models.Task
-	for cursor.Next(ctx) {
-		var task models.Task
-		if err := cursor.Decode(&task); err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding task", "")
-			return
-		}
-		tasks = append(tasks, task)
-	}
-
-	if err = cursor.Err(); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Cursor error", "")
+	page, err := utils.FindPage[models.Task](ctx, database.DB.Collection("tasks"), query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching tasks", "")
 		return
 	}
 
-	utils.Logger.Info("Fetched team projects successfully")
+	utils.SetPageHeaders(w, r, query, page)
+	utils.RequestLogger(r).Info("Fetched team projects successfully")
 	utils.RespondWithJSON(w, http.StatusOK, "Projects retrieved", map[string]interface{}{
-		"project_id": projectID.Hex(),
-		"tasks":      tasks,
+		"project_id":  projectID.Hex(),
+		"tasks":       page.Items,
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
 	})
 
 }
 
+// taskListSpec is shared by every endpoint that lists tasks.
+var taskListSpec = utils.ListSpec{
+	Sort: map[string]string{
+		"position":  "position",
+		"status":    "status",
+		"title":     "title",
+		"createdAt": "createdAt",
+		"dueDate":   "dueDate",
+		"priority":  "priorityRank",
+	},
+	DefaultSort: "status,position",
+	Equals:      map[string]string{"status": "status", "creator": "createdBy", "priority": "priority"},
+	ObjectIDs:   map[string]string{"assignee": "assigned", "sprint": "sprintId"},
+	Arrays:      map[string]string{"labels": "labels"},
+	Ranges:      map[string]string{"created": "createdAt", "due": "dueDate"},
+}
+
 func GetTask(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
@@ -591,7 +674,7 @@ func GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	taskCollection := database.DB.Collection("tasks")
@@ -620,7 +703,359 @@ func GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.Logger.Info("Task fetched")
+	utils.RequestLogger(r).Info("Task fetched")
 	utils.RespondWithJSON(w, http.StatusOK, "Task fetched successfully", map[string]interface{}{"task": task})
 
 }
+
+func MoveTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	taskIDStr := vars["taskId"]
+	if taskIDStr == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Missing Task ID", "")
+		return
+	}
+
+	taskID, err := primitive.ObjectIDFromHex(taskIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Task ID", "")
+		return
+	}
+
+	// prevTaskId and nextTaskId are the cards the task is dropped between; leave
+	// both empty to drop it at the bottom of the column.
+	var body struct {
+		Status     string `json:"status"`
+		PrevTaskID string `json:"prevTaskId"`
+		NextTaskID string `json:"nextTaskId"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	taskCollection := database.DB.Collection("tasks")
+	var task models.Task
+
+	err = taskCollection.FindOne(ctx, bson.M{"_id": taskID}).Decode(&task)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding task", "")
+		}
+		return
+	}
+
+	if body.Status == "" {
+		body.Status = task.Status
+	}
+
+	var project models.Project
+	err = database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": task.ProjectId}).Decode(&project)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		return
+	}
+
+	if !services.ValidStatus(project, body.Status) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid status. Must be one of: "+strings.Join(services.Workflow(project), ", "), "")
+		return
+	}
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = memberCollection.FindOne(ctx, bson.M{"user": userID, "teamId": task.TeamId}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	if body.Status != task.Status && !(strings.EqualFold(member.Role, "Admin") && adminAllowed(r)) && task.AssignedTo.Hex() != userID {
+		utils.RespondWithError(w, http.StatusForbidden, "Only the assignee or an admin can change task status", "")
+		return
+	}
+
+	prevID, ok := neighbourID(ctx, w, taskCollection, body.PrevTaskID, task, body.Status)
+	if !ok {
+		return
+	}
+	nextID, ok := neighbourID(ctx, w, taskCollection, body.NextTaskID, task, body.Status)
+	if !ok {
+		return
+	}
+
+	var position string
+	if prevID.IsZero() && nextID.IsZero() {
+		position, err = services.NextPosition(ctx, task.ProjectId, body.Status)
+	} else {
+		position, err = services.PositionNear(ctx, task, body.Status, prevID, nextID)
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusConflict, "Unable to place task between the given tasks", "")
+		return
+	}
+
+	// Matching on the current status and position makes the move fail if someone
+	// else moved the card since it was read, instead of silently overwriting it.
+	update := bson.M{"$set": bson.M{"position": position}}
+	done := false
+	if body.Status != task.Status {
+		update, done = services.StatusUpdate(project, body.Status, position, time.Now())
+	}
+
+	result, err := taskCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": taskID, "status": task.Status, "position": task.Position},
+		update,
+	)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error moving task", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusConflict, "Task was moved by someone else, reload the board", "")
+		return
+	}
+
+	if body.Status != task.Status {
+		utils.LogStatusChange(ctx, userID, task, task.Status, body.Status, done)
+		publishStatusChange(userID, task, body.Status)
+	} else {
+		utils.Log(
+			ctx,
+			userID,
+			task.TeamId.Hex(),
+			task.ProjectId.Hex(),
+			taskIDStr,
+			"Move Task",
+			userID+" moved '"+taskIDStr+"' within "+body.Status)
+	}
+
+	utils.RequestLogger(r).Info("Task moved successfully")
+	utils.RespondWithJSON(w, http.StatusOK, "Task moved", map[string]interface{}{
+		"taskID":   taskIDStr,
+		"status":   body.Status,
+		"position": position,
+	})
+}
+
+func publishStatusChange(userID string, task models.Task, status string) {
+	events.Publish(events.Event{
+		Type:      events.TaskStatusChanged,
+		ActorID:   userID,
+		TeamID:    task.TeamId.Hex(),
+		ProjectID: task.ProjectId.Hex(),
+		TaskID:    task.ID.Hex(),
+		Data:      map[string]interface{}{"title": task.Title, "from": task.Status, "to": status},
+	})
+}
+
+// neighbourID checks a task the moved card is dropped next to is in the target
+// column. It writes the error response itself and reports false when the
+// request should stop.
+func neighbourID(ctx context.Context, w http.ResponseWriter, taskCollection *mongo.Collection, neighbourIDStr string, task models.Task, status string) (primitive.ObjectID, bool) {
+	if neighbourIDStr == "" {
+		return primitive.NilObjectID, true
+	}
+
+	id, err := primitive.ObjectIDFromHex(neighbourIDStr)
+	if err != nil || id == task.ID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid neighbour Task ID", "")
+		return primitive.NilObjectID, false
+	}
+
+	err = taskCollection.FindOne(ctx, bson.M{"_id": id, "projectId": task.ProjectId, "status": status}).Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Neighbour task not found in target column", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding neighbour task", "")
+		}
+		return primitive.NilObjectID, false
+	}
+
+	return id, true
+}
+
+// SetRecurrence replaces a task's recurrence rule, or stops it repeating when
+// neither rrule nor recurrence is given.
+func SetRecurrence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only PUT Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	taskIDStr := vars["taskId"]
+	taskID, err := primitive.ObjectIDFromHex(taskIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Task ID", "")
+		return
+	}
+
+	var body struct {
+		RRule      string             `json:"rrule"`
+		Recurrence *models.Recurrence `json:"recurrence"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	taskCollection := database.DB.Collection("tasks")
+	var task models.Task
+
+	err = taskCollection.FindOne(ctx, bson.M{"_id": taskID}).Decode(&task)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Task not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding task", "")
+		}
+		return
+	}
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = memberCollection.FindOne(ctx, bson.M{"user": userID, "teamId": task.TeamId}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	if task.CreatedBy != userID && !(strings.EqualFold(member.Role, "Admin") && adminAllowed(r)) {
+		utils.RespondWithError(w, http.StatusForbidden, "Not Permited to perform action", "")
+		return
+	}
+
+	if task.RecurrenceOf != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Instances of a recurring task cannot repeat themselves", "")
+		return
+	}
+
+	recurrence, err := parseRecurrence(body.RRule, body.Recurrence, task.DueDate)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"recurrence": "", "nextRunAt": ""}}
+	if recurrence != nil {
+		set := bson.M{"recurrence": recurrence}
+		unset := bson.M{}
+		if next := firstRun(*recurrence, time.Now()); next != nil {
+			set["nextRunAt"] = *next
+		} else {
+			unset["nextRunAt"] = ""
+		}
+
+		update = bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+	}
+
+	result, err := taskCollection.UpdateOne(ctx, bson.M{"_id": taskID}, update)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error updating recurrence", "")
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Task not found", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		task.TeamId.Hex(),
+		task.ProjectId.Hex(),
+		taskIDStr,
+		"Update Recurrence",
+		userID+" updated recurrence of '"+taskIDStr+"'")
+
+	utils.RequestLogger(r).Info("Task recurrence updated")
+	utils.RespondWithJSON(w, http.StatusOK, "Recurrence updated", map[string]interface{}{
+		"taskID":     taskIDStr,
+		"recurrence": recurrence,
+	})
+}
+
+// parseRecurrence accepts either an RRULE string or a structured rule. Rules
+// without a start are anchored on the due date, or on now when there is none.
+func parseRecurrence(rrule string, recurrence *models.Recurrence, dueDate *time.Time) (*models.Recurrence, error) {
+	start := time.Now()
+	if dueDate != nil {
+		start = *dueDate
+	}
+
+	if rrule != "" {
+		parsed, err := services.ParseRRule(rrule, start)
+		if err != nil {
+			return nil, err
+		}
+		return &parsed, nil
+	}
+
+	if recurrence == nil {
+		return nil, nil
+	}
+
+	if recurrence.Interval == 0 {
+		recurrence.Interval = 1
+	}
+	if recurrence.Start.IsZero() {
+		recurrence.Start = start
+	}
+
+	if err := services.ValidateRecurrence(*recurrence); err != nil {
+		return nil, err
+	}
+	return recurrence, nil
+}
+
+// firstRun is when the scheduler should create the first instance after now.
+// The task the rule is set on stands in for any occurrence up to now.
+func firstRun(recurrence models.Recurrence, now time.Time) *time.Time {
+	next, ok := services.NextOccurrence(recurrence, now)
+	if !ok {
+		return nil
+	}
+	return &next
+}
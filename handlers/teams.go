@@ -10,7 +10,10 @@ import (
 	"time"
 
 	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
+	"github.com/Loboo34/collab-api/metrics"
 	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
 	"github.com/Loboo34/collab-api/utils"
 	"github.com/gorilla/mux"
 
@@ -49,7 +52,7 @@ func CreateTeam(w http.ResponseWriter, r *http.Request) {
 		Members:     []string{userID},
 		CreatedBy:   userID,
 		CreatedAt:   time.Now(),
-		Projects:    []string{},
+		Projects:    []primitive.ObjectID{},
 	}
 
 	membersCollection := database.DB.Collection("team-members")
@@ -65,25 +68,25 @@ func CreateTeam(w http.ResponseWriter, r *http.Request) {
 	userCollection := database.DB.Collection("users")
 	userObjID, _ := primitive.ObjectIDFromHex(userID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	_, err = teamCollection.InsertOne(ctx, team)
 	if err != nil {
-		utils.Logger.Warn("Failed to Create team")
+		utils.RequestLogger(r).Warn("Failed to Create team")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating Team", "")
 		return
 	}
 
 	_, err = membersCollection.InsertOne(ctx, members)
 	if err != nil {
-		utils.Logger.Warn("Failed to create team admin")
+		utils.RequestLogger(r).Warn("Failed to create team admin")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error saving Admin", "")
 	}
 
 	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjID}, bson.M{"$addToSet": bson.M{"teams": team.ID}})
 
-	utils.Logger.Info("Team created successfully")
+	utils.RequestLogger(r).Info("Team created successfully")
 	utils.RespondWithJSON(w, http.StatusCreated, "Team created Successfully", map[string]interface{}{"team_id": team.ID.Hex(),
 		"name": team.Name})
 }
@@ -115,7 +118,7 @@ func UpdateTeam(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	teamCollection := database.DB.Collection("teams")
@@ -162,7 +165,7 @@ func UpdateTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.Logger.Info("Team Updated")
+	utils.RequestLogger(r).Info("Team Updated")
 	utils.RespondWithJSON(w, http.StatusOK, "Update Seccessful", map[string]interface{}{"team": update})
 
 }
@@ -179,7 +182,7 @@ func InviteMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	type InveteRequest struct {
@@ -214,7 +217,7 @@ func InviteMember(w http.ResponseWriter, r *http.Request) {
 	memberCollection := database.DB.Collection("team-members")
 	var member models.TeamMember
 
-	err = memberCollection.FindOne(ctx, bson.M{"user": userId, "teamId": teamObjId, "role": "Admin"}).Decode(&member)
+	err = findAdmin(ctx, r, userId, teamObjId, &member)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
@@ -246,7 +249,7 @@ func InviteMember(w http.ResponseWriter, r *http.Request) {
 	inviteCollection := database.DB.Collection("invites")
 	var existingInvite models.Invite
 
-	err = inviteCollection.FindOne(ctx, bson.M{"email": user.Email, "teamId": teamObjId, "status": "pending"}).Decode(&existingInvite)
+	err = inviteCollection.FindOne(ctx, bson.M{"email": user.Email, "teamId": teamObjId, "status": "pending", "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&existingInvite)
 	if err == nil {
 		utils.RespondWithError(w, http.StatusConflict, "Invite already exists", "")
 		return
@@ -267,22 +270,31 @@ func InviteMember(w http.ResponseWriter, r *http.Request) {
 		Status:    "pending",
 		SentBy:    userId,
 		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(models.InviteTTL),
 	}
 
 	_, err = inviteCollection.InsertOne(ctx, invite)
 	if err != nil {
-		utils.Logger.Warn("Failed to create invite")
+		utils.RequestLogger(r).Warn("Failed to create invite")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating invite", "")
 		return
 	}
 
-	inviteLink := "http://localhost:3000/invite/accept?token=" + inviteToken
+	inviteLink := appURL + "/invite/accept?token=" + inviteToken
 
 	if err := utils.SendInviteEmail(user.Email, inviteLink); err != nil {
-		utils.Logger.Warn("Failed to send email")
+		utils.RequestLogger(r).Warn("Failed to send email")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error sending email", "")
 		return
 	}
+	metrics.InvitesSent.Inc()
+
+	events.Publish(events.Event{
+		Type:    events.MemberInvited,
+		ActorID: userId,
+		TeamID:  teamObjId.Hex(),
+		Data:    map[string]interface{}{"userId": user.ID.Hex(), "teamName": team.Name, "inviteId": invite.ID.Hex()},
+	})
 
 	utils.RespondWithJSON(w, http.StatusCreated, "Invitation sent successfully", map[string]interface{}{
 		"email":     user.Email,
@@ -310,12 +322,12 @@ func AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	inviteCollection := database.DB.Collection("invites")
 	var invite models.Invite
-	err = inviteCollection.FindOne(ctx, bson.M{"token": inviteToken}).Decode(&invite)
+	err = inviteCollection.FindOne(ctx, bson.M{"token": inviteToken, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&invite)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			utils.RespondWithError(w, http.StatusNotFound, "Invalid or expired invite", "")
@@ -354,8 +366,12 @@ func AcceptInvite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_, err = membersCollection.InsertOne(ctx, newMember)
+	if mongo.IsDuplicateKeyError(err) {
+		utils.RespondWithError(w, http.StatusConflict, "User Already exists in team", "")
+		return
+	}
 	if err != nil {
-		utils.Logger.Warn("Failed to Add user to team")
+		utils.RequestLogger(r).Warn("Failed to Add user to team")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding member to team", "")
 		return
 	}
@@ -366,7 +382,7 @@ func AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		bson.M{"$addToSet": bson.M{"teams": invite.TeamID}},
 	)
 	if err != nil {
-		utils.Logger.Warn("Failed to update user teams")
+		utils.RequestLogger(r).Warn("Failed to update user teams")
 	}
 
 	teamCollection := database.DB.Collection("teams")
@@ -376,7 +392,7 @@ func AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		bson.M{"$addToSet": bson.M{"members": userID}},
 	)
 	if err != nil {
-		utils.Logger.Warn("Failed to update team members")
+		utils.RequestLogger(r).Warn("Failed to update team members")
 	}
 
 	_, err = inviteCollection.UpdateOne(
@@ -389,6 +405,13 @@ func AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	events.Publish(events.Event{
+		Type:    events.MemberJoined,
+		ActorID: userID,
+		TeamID:  invite.TeamID.Hex(),
+		Data:    map[string]interface{}{"userId": userID, "fullname": user.FullName, "role": newMember.Role},
+	})
+
 	utils.RespondWithJSON(w, http.StatusOK, "Invite accepted successfully", map[string]interface{}{
 		"team_id": invite.TeamID.Hex(),
 		"user_id": userID,
@@ -415,13 +438,13 @@ func DeclineInvite(w http.ResponseWriter, r *http.Request) {
 
 	id, _ := primitive.ObjectIDFromHex(userID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	inviteCollection := database.DB.Collection("invites")
 	var invite models.Invite
 
-	err = inviteCollection.FindOne(ctx, bson.M{"token": inviteToken}).Decode(&invite)
+	err = inviteCollection.FindOne(ctx, bson.M{"token": inviteToken, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&invite)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			utils.RespondWithError(w, http.StatusNotFound, "Invalid or expired invite", "")
@@ -438,7 +461,7 @@ func DeclineInvite(w http.ResponseWriter, r *http.Request) {
 
 	_, err = inviteCollection.UpdateOne(ctx, bson.M{"_id": invite.ID}, bson.M{"$set": bson.M{"status": "declined"}})
 	if err != nil {
-		utils.Logger.Warn("Failed to decline Invitation")
+		utils.RequestLogger(r).Warn("Failed to decline Invitation")
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error declinign invitation", "")
 		return
 	}
@@ -471,7 +494,7 @@ func GetTeamMembers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	teamCollection := database.DB.Collection("teams")
@@ -500,30 +523,31 @@ func GetTeamMembers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cursor, err := membersCollection.Find(ctx, bson.M{"teamId": teamID})
+	query, err := utils.ParseListQuery(r, bson.M{"teamId": teamID}, utils.ListSpec{
+		Sort:        map[string]string{"joinedAt": "joinedat", "role": "role"},
+		DefaultSort: "joinedAt",
+		Equals:      map[string]string{"role": "role"},
+		Ranges:      map[string]string{"joined": "joinedat"},
+	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching ", "")
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
 		return
 	}
 
-	defer cursor.Close(ctx)
-	var members []models.TeamMember
-	for cursor.Next(ctx) {
-		var member models.TeamMember
-		if err := cursor.Decode(&member); err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching ", "")
-			return
-		}
-		members = append(members, member)
-	}
-
-	if err = cursor.Err(); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Cursor error", "")
+	page, err := utils.FindPage[models.TeamMember](ctx, membersCollection, query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching ", "")
 		return
 	}
 
-	utils.Logger.Info("Fetched All team members")
-	utils.RespondWithJSON(w, http.StatusOK, "", map[string]interface{}{"members": members})
+	utils.SetPageHeaders(w, r, query, page)
+	utils.RequestLogger(r).Info("Fetched All team members")
+	utils.RespondWithJSON(w, http.StatusOK, "", map[string]interface{}{
+		"members":     page.Items,
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+	})
 }
 
 func ChangeRole(w http.ResponseWriter, r *http.Request) {
@@ -560,7 +584,7 @@ func ChangeRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	teamCollection := database.DB.Collection("teams")
@@ -587,18 +611,18 @@ func ChangeRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := membersCollection.UpdateOne(ctx, bson.M{"teamId": teamID, "user": body.MemberID}, bson.M{"$set": bson.M{"role": body.Role}})
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Faile to update role", "")
+	err = services.SetMemberRole(ctx, teamID, body.MemberID, body.Role)
+	if err == services.ErrNotMember {
+		utils.RespondWithError(w, http.StatusNotFound, "Failed to find member", "")
 		return
 	}
-
-	if result.MatchedCount == 0 {
-		utils.RespondWithError(w, http.StatusNotFound, "Failed to find member", "")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Faile to update role", "")
 		return
 	}
 
 	utils.Log(
+		ctx,
 		userID,
 		teamIDStr,
 		"",
@@ -607,7 +631,7 @@ func ChangeRole(w http.ResponseWriter, r *http.Request) {
 		userID+"Changed'"+body.MemberID+"' role to "+body.Role,
 	)
 
-	utils.Logger.Info("Changed users role successfully")
+	utils.RequestLogger(r).Info("Changed users role successfully")
 	utils.RespondWithJSON(w, http.StatusOK, "Role changed successfuly", map[string]interface{}{"user": member.ID, "role": body.Role})
 }
 
@@ -623,44 +647,43 @@ func GetTeams(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	teamsCollection := database.DB.Collection("teams")
-	cursor, err := teamsCollection.Find(ctx, bson.M{"members": userID})
+	query, err := utils.ParseListQuery(r, bson.M{"members": userID}, utils.ListSpec{
+		Sort:        map[string]string{"name": "name", "createdAt": "createdAt"},
+		DefaultSort: "createdAt",
+		Equals:      map[string]string{"creator": "createdby"},
+		Ranges:      map[string]string{"created": "createdAt"},
+	})
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding teams", "")
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var teams []models.Team
-	for cursor.Next(ctx) {
-		var team models.Team
-		if err := cursor.Decode(&team); err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding team", "")
-			return
-		}
-		teams = append(teams, team)
-	}
 
-	if err = cursor.Err(); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Cursor error", "")
+	page, err := utils.FindPage[models.Team](ctx, database.DB.Collection("teams"), query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding teams", "")
 		return
 	}
 
-	if len(teams) == 0 {
+	utils.SetPageHeaders(w, r, query, page)
+
+	if page.Total == 0 {
 		utils.RespondWithJSON(w, http.StatusOK, "No teams found", map[string]interface{}{
 			"teams": []models.Team{},
 			"count": 0,
+			"total": 0,
 		})
 		return
 	}
 
-	utils.Logger.Info("Fetched user teams successfully")
+	utils.RequestLogger(r).Info("Fetched user teams successfully")
 	utils.RespondWithJSON(w, http.StatusOK, "Teams retrieved successfully", map[string]interface{}{
-		"teams": teams,
-		"count": len(teams),
+		"teams":       page.Items,
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
 	})
 }
 
@@ -698,7 +721,7 @@ func RemoveMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	teamCollection := database.DB.Collection("teams")
@@ -743,7 +766,7 @@ func RemoveMember(w http.ResponseWriter, r *http.Request) {
 		bson.M{"$pull": bson.M{"members": request.User}},
 	)
 	if err != nil {
-		utils.Logger.Warn("Failed to update team members array")
+		utils.RequestLogger(r).Warn("Failed to update team members array")
 
 	}
 
@@ -755,11 +778,12 @@ func RemoveMember(w http.ResponseWriter, r *http.Request) {
 		bson.M{"$pull": bson.M{"teams": teamID}},
 	)
 	if err != nil {
-		utils.Logger.Warn("Failed to update user's teams array")
+		utils.RequestLogger(r).Warn("Failed to update user's teams array")
 
 	}
 
 	utils.Log(
+		ctx,
 		userID,
 		teamIDStr,
 		"",
@@ -768,7 +792,7 @@ func RemoveMember(w http.ResponseWriter, r *http.Request) {
 		userID+"Removed '"+request.User+"' from team",
 	)
 
-	utils.Logger.Info("User successfully removed from team")
+	utils.RequestLogger(r).Info("User successfully removed from team")
 	utils.RespondWithJSON(w, http.StatusOK, "Member removed successfully", map[string]interface{}{
 		"team_id":   teamID.Hex(),
 		"member_id": request.User,
@@ -803,7 +827,7 @@ func DeleteTeam(w http.ResponseWriter, r *http.Request) {
 	var team models.Team
 	teamCollection := database.DB.Collection("teams")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	err = teamCollection.FindOne(ctx, bson.M{"_id": teamID}).Decode(&team)
@@ -841,6 +865,97 @@ func DeleteTeam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.Logger.Info("Deleted Team")
+	utils.RequestLogger(r).Info("Deleted Team")
 	utils.RespondWithJSON(w, http.StatusOK, "Team successfuly deleted", map[string]interface{}{"Team deleted by": userID, "team": team})
 }
+
+// UnlockMember clears a team member's failed login attempts, lifting a
+// lockout before it runs out.
+func UnlockMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	var request struct {
+		User string `json:"user"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	memberObjID, err := primitive.ObjectIDFromHex(request.User)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	membersCollection := database.DB.Collection("team-members")
+	var admin models.TeamMember
+
+	err = findAdmin(ctx, r, userID, teamID, &admin)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+	err = membersCollection.FindOne(ctx, bson.M{"user": request.User, "teamId": teamID}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding Member", "")
+		}
+		return
+	}
+
+	var user models.User
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": memberObjID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding user", "")
+		}
+		return
+	}
+
+	if err := services.ClearLoginFailures(ctx, services.AccountLoginKey(user.Email)); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error unlocking account", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		teamID.Hex(),
+		"",
+		"",
+		"Unlock Member",
+		userID+" unlocked the account of "+user.FullName)
+
+	utils.RequestLogger(r).Info("Account unlocked")
+	utils.RespondWithJSON(w, http.StatusOK, "Account unlocked", map[string]interface{}{"user": request.User})
+}
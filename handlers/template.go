@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// SaveProjectTemplate stores a project's workflow and tasks as a team template.
+// Due dates are kept as day offsets from the project's start date.
+func SaveProjectTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectIDStr := vars["projectId"]
+	projectID, err := primitive.ObjectIDFromHex(projectIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Project ID", "")
+		return
+	}
+
+	var request struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	projectCollection := database.DB.Collection("projects")
+	var project models.Project
+
+	err = projectCollection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Project not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding project", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, project.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusForbidden, "Only team admins can manage templates", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	// Instances created by the recurring task scheduler are left out; they
+	// belong to the original project's calendar, not the template.
+	taskCollection := database.DB.Collection("tasks")
+	opts := options.Find().SetSort(bson.D{{Key: "status", Value: 1}, {Key: "position", Value: 1}})
+	cursor, err := taskCollection.Find(ctx, bson.M{"projectId": projectID, "recurrenceOf": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching tasks", "")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var tasks []models.Task
+	if err = cursor.All(ctx, &tasks); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding tasks", "")
+		return
+	}
+
+	anchor := project.CreatedAt
+	if project.StartDate != nil {
+		anchor = *project.StartDate
+	}
+
+	taskTemplates := []models.TaskTemplate{}
+	for _, task := range tasks {
+		taskTemplate := models.TaskTemplate{
+			Title:       task.Title,
+			Description: task.Description,
+			Status:      task.Status,
+		}
+
+		for _, item := range task.Checklist {
+			taskTemplate.Checklist = append(taskTemplate.Checklist, models.ChecklistItem{Text: item.Text})
+		}
+
+		if task.DueDate != nil {
+			offset := int(math.Round(task.DueDate.Sub(anchor).Hours() / 24))
+			taskTemplate.DueOffset = &offset
+		}
+
+		taskTemplates = append(taskTemplates, taskTemplate)
+	}
+
+	if request.Name == "" {
+		request.Name = project.Name
+	}
+	if request.Description == "" {
+		request.Description = project.Description
+	}
+
+	template := models.ProjectTemplate{
+		ID:          primitive.NewObjectID(),
+		TeamId:      project.TeamId,
+		Name:        request.Name,
+		Description: request.Description,
+		Workflow:    project.Workflow,
+		Tasks:       taskTemplates,
+		CreatedBy:   userID,
+		CreatedAt:   time.Now(),
+	}
+
+	_, err = database.DB.Collection("templates").InsertOne(ctx, template)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to save template")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error saving template", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		project.TeamId.Hex(),
+		projectIDStr,
+		"",
+		"Save Template",
+		userID+" saved project '"+projectIDStr+"' as template '"+template.Name+"'")
+
+	utils.RequestLogger(r).Info("Template saved")
+	utils.RespondWithJSON(w, http.StatusCreated, "Template saved", map[string]interface{}{"template": template})
+}
+
+func GetTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = memberCollection.FindOne(ctx, bson.M{"user": userID, "teamId": teamID}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := database.DB.Collection("templates").Find(ctx, bson.M{"teamId": teamID}, opts)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching templates", "")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.ProjectTemplate{}
+	if err = cursor.All(ctx, &templates); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding templates", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Fetched team templates")
+	utils.RespondWithJSON(w, http.StatusOK, "Templates retrieved", map[string]interface{}{
+		"team_id":   teamID.Hex(),
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only DELETE Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	templateIDStr := vars["templateId"]
+	templateID, err := primitive.ObjectIDFromHex(templateIDStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Template ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	templateCollection := database.DB.Collection("templates")
+	var template models.ProjectTemplate
+
+	err = templateCollection.FindOne(ctx, bson.M{"_id": templateID}).Decode(&template)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Template not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding template", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, template.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusForbidden, "Only team admins can manage templates", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	result, err := templateCollection.DeleteOne(ctx, bson.M{"_id": templateID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting template", "")
+		return
+	}
+
+	if result.DeletedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Template not found", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		template.TeamId.Hex(),
+		"",
+		"",
+		"Delete Template",
+		userID+" deleted template '"+template.Name+"'")
+
+	utils.RequestLogger(r).Info("Template deleted")
+	utils.RespondWithJSON(w, http.StatusOK, "Template deleted", map[string]interface{}{"template_id": templateIDStr})
+}
+
+// CreateProjectFromTemplate creates a project with the template's workflow and
+// tasks, shifting each due date to the same offset from the new start date.
+func CreateProjectFromTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	templateID, err := primitive.ObjectIDFromHex(vars["templateId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Template ID", "")
+		return
+	}
+
+	var request struct {
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		StartDate   *time.Time `json:"startDate"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var template models.ProjectTemplate
+	err = database.DB.Collection("templates").FindOne(ctx, bson.M{"_id": templateID}).Decode(&template)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Template not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding template", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, template.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusForbidden, "Only team admins can manage templates", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	if request.Name == "" {
+		request.Name = template.Name
+	}
+	if request.Description == "" {
+		request.Description = template.Description
+	}
+
+	now := time.Now()
+	startDate := now
+	if request.StartDate != nil {
+		startDate = *request.StartDate
+	}
+
+	project := models.Project{
+		ID:          primitive.NewObjectID(),
+		Name:        request.Name,
+		Description: request.Description,
+		TeamId:      template.TeamId,
+		CreatedBy:   userID,
+		CreatedAt:   now,
+		StartDate:   &startDate,
+		Workflow:    template.Workflow,
+		Tasks:       []string{},
+	}
+
+	if err = services.CreateProject(ctx, &project); err != nil {
+		utils.RequestLogger(r).Warn("Failed to create project from template")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating project", "")
+		return
+	}
+
+	for _, taskTemplate := range template.Tasks {
+		status := taskTemplate.Status
+		if !services.ValidStatus(project, status) {
+			status = services.Workflow(project)[0]
+		}
+
+		task := models.Task{
+			ID:          primitive.NewObjectID(),
+			Title:       taskTemplate.Title,
+			Description: taskTemplate.Description,
+			Status:      status,
+			Checklist:   taskTemplate.Checklist,
+			TeamId:      template.TeamId,
+			ProjectId:   project.ID,
+			CreatedBy:   userID,
+			CreatedAt:   now,
+		}
+
+		if taskTemplate.DueOffset != nil {
+			dueDate := startDate.AddDate(0, 0, *taskTemplate.DueOffset)
+			task.DueDate = &dueDate
+		}
+
+		if err = services.CreateTask(ctx, &task); err != nil {
+			utils.RequestLogger(r).Warn("Failed to create task from template")
+
+			// Don't leave a half-filled project behind; the request may have
+			// failed on its deadline, so the rollback gets its own.
+			rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			if err := services.RemoveProject(rollbackCtx, project); err != nil {
+				utils.RequestLogger(r).Warn("Failed to roll back project created from template")
+			}
+
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error creating project tasks", "")
+			return
+		}
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		template.TeamId.Hex(),
+		project.ID.Hex(),
+		"",
+		"Created Project",
+		userID+" created project '"+project.Name+"' from template '"+template.Name+"'")
+
+	utils.RequestLogger(r).Info("Project created from template")
+	utils.RespondWithJSON(w, http.StatusCreated, "Project created from template", map[string]interface{}{
+		"projectID": project.ID.Hex(),
+		"name":      project.Name,
+		"tasks":     len(template.Tasks),
+	})
+}
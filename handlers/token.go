@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// maxTokenLifetime bounds expiresInDays; tokens without it never expire.
+const maxTokenLifetime = 365
+
+type tokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// newAPIToken checks the request for a new token, writing the error response
+// itself when it is invalid.
+func newAPIToken(w http.ResponseWriter, body tokenRequest) (models.APIToken, bool) {
+	token := models.APIToken{Name: strings.TrimSpace(body.Name)}
+	if token.Name == "" || len(token.Name) > 100 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name is required and must be at most 100 characters", "")
+		return token, false
+	}
+
+	if len(body.Scopes) == 0 {
+		body.Scopes = []string{models.ScopeRead}
+	}
+	seen := map[string]bool{}
+	for _, scope := range body.Scopes {
+		if !models.TokenScopes[scope] {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown scope "+scope+". Must be read, write or admin", "")
+			return token, false
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			token.Scopes = append(token.Scopes, scope)
+		}
+	}
+
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > maxTokenLifetime {
+		utils.RespondWithError(w, http.StatusBadRequest, "expiresInDays must be between 1 and 365", "")
+		return token, false
+	}
+	if body.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, body.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	return token, true
+}
+
+// adminAllowed reports whether the request may use the caller's Admin role.
+// API tokens only can with the admin scope, whatever the owner's role is.
+func adminAllowed(r *http.Request) bool {
+	token, ok := r.Context().Value("token").(models.APIToken)
+	return !ok || token.HasScope(models.ScopeAdmin)
+}
+
+// findAdmin loads the caller's membership of a team they administer. Like the
+// lookup it wraps, it returns mongo.ErrNoDocuments when they aren't an admin
+// there, or are calling with a token that can't act as one.
+func findAdmin(ctx context.Context, r *http.Request, userID string, teamID primitive.ObjectID, member *models.TeamMember) error {
+	if !adminAllowed(r) {
+		return mongo.ErrNoDocuments
+	}
+	return database.DB.Collection("team-members").FindOne(ctx, bson.M{"user": userID, "teamId": teamID, "role": "Admin"}).Decode(member)
+}
+
+// rejectAPIToken stops tokens from minting or listing other tokens, or
+// changing the account's credentials, so a leaked token can't be used to
+// outlive its own revocation or take the account over.
+func rejectAPIToken(w http.ResponseWriter, r *http.Request) bool {
+	if r.Context().Value("token") != nil {
+		utils.RespondWithError(w, http.StatusForbidden, "Sign in with a password to change credentials or tokens", "")
+		return true
+	}
+	return false
+}
+
+// CreateToken issues a personal access token. The token itself is only
+// returned here; only its hash is stored.
+func CreateToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	var body tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	token, ok := newAPIToken(w, body)
+	if !ok {
+		return
+	}
+	token.UserID = userID
+	token.CreatedBy = userID
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	plain, err := services.CreateAPIToken(ctx, &token)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to create API token")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating token", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("API token created")
+	utils.RespondWithJSON(w, http.StatusCreated, "Token created", map[string]interface{}{
+		"token":   plain,
+		"details": token,
+	})
+}
+
+func GetTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.DB.Collection("api-tokens").Find(ctx, bson.M{"userId": userID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching tokens", "")
+		return
+	}
+
+	tokens := []models.APIToken{}
+	if err = cursor.All(ctx, &tokens); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding tokens", "")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Tokens retrieved", map[string]interface{}{
+		"tokens": tokens,
+		"count":  len(tokens),
+	})
+}
+
+// RevokeToken revokes one of the caller's tokens. Revoked tokens stay listed
+// with revokedAt set.
+func RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only DELETE Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	tokenID, err := primitive.ObjectIDFromHex(vars["tokenId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Token ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := database.DB.Collection("api-tokens").UpdateOne(ctx,
+		bson.M{"_id": tokenID, "userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking token", "")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Token not found", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("API token revoked")
+	utils.RespondWithJSON(w, http.StatusOK, "Token revoked", map[string]interface{}{"tokenId": tokenID.Hex()})
+}
+
+// CreateServiceAccount adds a passwordless member to the team for automation
+// and issues its first token, returned only here.
+func CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	var body struct {
+		tokenRequest
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+	if body.Role == "" {
+		body.Role = "Member"
+	}
+	if body.Role != "Admin" && body.Role != "Member" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Role must be Admin or Member", "")
+		return
+	}
+
+	token, ok := newAPIToken(w, body.tokenRequest)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, teamID, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	now := time.Now()
+	account := models.User{
+		ID:             primitive.NewObjectID(),
+		FullName:       token.Name,
+		Email:          "svc-" + primitive.NewObjectID().Hex() + "@service.invalid",
+		Teams:          []primitive.ObjectID{teamID},
+		CreatedAt:      now,
+		ServiceAccount: true,
+		TeamId:         &teamID,
+	}
+
+	_, err = database.DB.Collection("users").InsertOne(ctx, account)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating service account", "")
+		return
+	}
+
+	_, err = memberCollection.InsertOne(ctx, models.TeamMember{
+		ID:       primitive.NewObjectID(),
+		TeamId:   teamID,
+		User:     account.ID.Hex(),
+		Role:     body.Role,
+		JoinedAt: now,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error adding service account to team", "")
+		return
+	}
+
+	token.UserID = account.ID.Hex()
+	token.CreatedBy = userID
+	plain, err := services.CreateAPIToken(ctx, &token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating token", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		teamID.Hex(),
+		"",
+		"",
+		"Create Service Account",
+		userID+" added service account "+account.FullName+" as "+body.Role)
+
+	utils.RequestLogger(r).Info("Service account created")
+	utils.RespondWithJSON(w, http.StatusCreated, "Service account created", map[string]interface{}{
+		"account": account,
+		"role":    body.Role,
+		"token":   plain,
+		"details": token,
+	})
+}
+
+func GetServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	memberCollection := database.DB.Collection("team-members")
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, teamID, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	cursor, err := database.DB.Collection("users").Find(ctx, bson.M{"serviceAccount": true, "teamId": teamID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching service accounts", "")
+		return
+	}
+
+	var accounts []models.User
+	if err = cursor.All(ctx, &accounts); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding service accounts", "")
+		return
+	}
+
+	accountIDs := make([]string, len(accounts))
+	for i, account := range accounts {
+		accountIDs[i] = account.ID.Hex()
+	}
+
+	roles := map[string]string{}
+	cursor, err = memberCollection.Find(ctx, bson.M{"teamId": teamID, "user": bson.M{"$in": accountIDs}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching members", "")
+		return
+	}
+	var members []models.TeamMember
+	if err = cursor.All(ctx, &members); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding members", "")
+		return
+	}
+	for _, m := range members {
+		roles[m.User] = m.Role
+	}
+
+	tokens := map[string][]models.APIToken{}
+	cursor, err = database.DB.Collection("api-tokens").Find(ctx, bson.M{"userId": bson.M{"$in": accountIDs}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching tokens", "")
+		return
+	}
+	var allTokens []models.APIToken
+	if err = cursor.All(ctx, &allTokens); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding tokens", "")
+		return
+	}
+	for _, token := range allTokens {
+		tokens[token.UserID] = append(tokens[token.UserID], token)
+	}
+
+	result := []map[string]interface{}{}
+	for _, account := range accounts {
+		result = append(result, map[string]interface{}{
+			"account": account,
+			"role":    roles[account.ID.Hex()],
+			"tokens":  tokens[account.ID.Hex()],
+		})
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Service accounts retrieved", map[string]interface{}{
+		"serviceAccounts": result,
+		"count":           len(result),
+	})
+}
+
+// findAdminServiceAccount loads the service account named in the URL and
+// checks the caller administers its team. It writes the error response
+// itself when either fails.
+func findAdminServiceAccount(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string) (models.User, bool) {
+	var account models.User
+
+	vars := mux.Vars(r)
+	accountID, err := primitive.ObjectIDFromHex(vars["accountId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Service Account ID", "")
+		return account, false
+	}
+
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": accountID, "serviceAccount": true}).Decode(&account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Service account not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding service account", "")
+		}
+		return account, false
+	}
+
+	var member models.TeamMember
+	err = database.DB.Collection("team-members").FindOne(ctx, bson.M{"user": userID, "teamId": account.TeamId, "role": "Admin"}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return account, false
+	}
+
+	return account, true
+}
+
+// CreateServiceAccountToken issues another token for a service account, e.g.
+// to rotate one that is about to expire.
+func CreateServiceAccountToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+	if rejectAPIToken(w, r) {
+		return
+	}
+
+	var body tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	token, ok := newAPIToken(w, body)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	account, ok := findAdminServiceAccount(ctx, w, r, userID)
+	if !ok {
+		return
+	}
+
+	token.UserID = account.ID.Hex()
+	token.CreatedBy = userID
+	plain, err := services.CreateAPIToken(ctx, &token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating token", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		account.TeamId.Hex(),
+		"",
+		"",
+		"Create Service Account Token",
+		userID+" created token "+token.Name+" for service account "+account.FullName)
+
+	utils.RequestLogger(r).Info("Service account token created")
+	utils.RespondWithJSON(w, http.StatusCreated, "Token created", map[string]interface{}{
+		"token":   plain,
+		"details": token,
+	})
+}
+
+// RevokeServiceAccountToken revokes one token of a service account.
+func RevokeServiceAccountToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only DELETE Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	tokenID, err := primitive.ObjectIDFromHex(vars["tokenId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Token ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	account, ok := findAdminServiceAccount(ctx, w, r, userID)
+	if !ok {
+		return
+	}
+
+	result, err := database.DB.Collection("api-tokens").UpdateOne(ctx,
+		bson.M{"_id": tokenID, "userId": account.ID.Hex(), "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking token", "")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Token not found", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		account.TeamId.Hex(),
+		"",
+		"",
+		"Revoke Service Account Token",
+		userID+" revoked a token of service account "+account.FullName)
+
+	utils.RequestLogger(r).Info("Service account token revoked")
+	utils.RespondWithJSON(w, http.StatusOK, "Token revoked", map[string]interface{}{"tokenId": tokenID.Hex()})
+}
+
+// DeleteServiceAccount revokes all of the account's tokens and removes it
+// from the team. The user document is kept so its activity still resolves.
+func DeleteServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only DELETE Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	account, ok := findAdminServiceAccount(ctx, w, r, userID)
+	if !ok {
+		return
+	}
+
+	_, err = database.DB.Collection("api-tokens").UpdateMany(ctx,
+		bson.M{"userId": account.ID.Hex(), "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error revoking tokens", "")
+		return
+	}
+
+	_, err = database.DB.Collection("team-members").DeleteOne(ctx, bson.M{"user": account.ID.Hex(), "teamId": account.TeamId})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error removing service account", "")
+		return
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": account.ID}, bson.M{"$set": bson.M{"teams": []primitive.ObjectID{}}})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error removing service account", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		account.TeamId.Hex(),
+		"",
+		"",
+		"Delete Service Account",
+		userID+" removed service account "+account.FullName)
+
+	utils.RequestLogger(r).Info("Service account deleted")
+	utils.RespondWithJSON(w, http.StatusOK, "Service account deleted", map[string]interface{}{"accountId": account.ID.Hex()})
+}
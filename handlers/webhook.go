@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+	"github.com/Loboo34/collab-api/webhooks"
+)
+
+var deliveryListSpec = utils.ListSpec{
+	Sort:        map[string]string{"createdAt": "createdAt"},
+	DefaultSort: "-createdAt",
+	Equals:      map[string]string{"status": "status", "event": "event"},
+}
+
+// CreateWebhook registers a URL to receive the team's events. The signing
+// secret is only returned here; store it on the receiving side.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	var body struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid json format", "")
+		return
+	}
+
+	if len(body.Events) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Subscribe to at least one event", "")
+		return
+	}
+
+	known := map[string]bool{}
+	for _, eventType := range events.Types {
+		known[eventType] = true
+	}
+	for _, eventType := range body.Events {
+		if !known[eventType] {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown event "+eventType+". Must be one of: "+strings.Join(events.Types, ", "), "")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, teamID, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	// Checked after the admin check, so only admins learn how a host resolves.
+	target, err := webhooks.ValidateURL(ctx, body.URL)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error generating secret", "")
+		return
+	}
+
+	hook := models.Webhook{
+		ID:        primitive.NewObjectID(),
+		TeamId:    teamID,
+		URL:       target.String(),
+		Secret:    hex.EncodeToString(secretBytes),
+		Events:    body.Events,
+		Active:    true,
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = database.DB.Collection("webhooks").InsertOne(ctx, hook)
+	if err != nil {
+		utils.RequestLogger(r).Warn("Failed to create webhook")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating webhook", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		teamID.Hex(),
+		"",
+		"",
+		"Create Webhook",
+		userID+" added a webhook for "+target.Host)
+
+	utils.RequestLogger(r).Info("Webhook created")
+	utils.RespondWithJSON(w, http.StatusCreated, "Webhook created", map[string]interface{}{
+		"webhook": hook,
+		"secret":  hook.Secret,
+	})
+}
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	teamID, err := primitive.ObjectIDFromHex(vars["teamId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Team ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, teamID, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	cursor, err := database.DB.Collection("webhooks").Find(ctx, bson.M{"teamId": teamID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching webhooks", "")
+		return
+	}
+
+	hooks := []models.Webhook{}
+	if err = cursor.All(ctx, &hooks); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error decoding webhooks", "")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, "Webhooks retrieved", map[string]interface{}{
+		"webhooks": hooks,
+		"count":    len(hooks),
+	})
+}
+
+// DeleteWebhook removes a webhook. Its pending deliveries are failed by the
+// delivery job; the delivery log is kept.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only DELETE Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	hook, ok := findAdminWebhook(ctx, w, r, userID)
+	if !ok {
+		return
+	}
+
+	_, err = database.DB.Collection("webhooks").DeleteOne(ctx, bson.M{"_id": hook.ID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error deleting webhook", "")
+		return
+	}
+
+	utils.Log(
+		ctx,
+		userID,
+		hook.TeamId.Hex(),
+		"",
+		"",
+		"Delete Webhook",
+		userID+" removed the webhook for "+hook.URL)
+
+	utils.RequestLogger(r).Info("Webhook deleted")
+	utils.RespondWithJSON(w, http.StatusOK, "Webhook deleted", map[string]interface{}{"webhookId": hook.ID.Hex()})
+}
+
+// GetWebhookDeliveries lists a webhook's delivery log, newest first.
+// Filter with status (pending, delivered, failed) and event.
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only GET Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	hook, ok := findAdminWebhook(ctx, w, r, userID)
+	if !ok {
+		return
+	}
+
+	query, err := utils.ParseListQuery(r, bson.M{"webhookId": hook.ID}, deliveryListSpec)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	page, err := utils.FindPage[models.WebhookDelivery](ctx, database.DB.Collection("webhook-deliveries"), query)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error fetching deliveries", "")
+		return
+	}
+
+	utils.SetPageHeaders(w, r, query, page)
+	utils.RespondWithJSON(w, http.StatusOK, "Deliveries retrieved", map[string]interface{}{
+		"deliveries":  page.Items,
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// RedeliverWebhook queues a fresh copy of an earlier delivery with the same
+// payload. The original stays in the log untouched.
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Only POST Allowed", "")
+		return
+	}
+
+	userID, err := utils.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Missing User ID", "")
+		return
+	}
+
+	vars := mux.Vars(r)
+	deliveryID, err := primitive.ObjectIDFromHex(vars["deliveryId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Delivery ID", "")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	deliveryCollection := database.DB.Collection("webhook-deliveries")
+	var delivery models.WebhookDelivery
+
+	err = deliveryCollection.FindOne(ctx, bson.M{"_id": deliveryID}).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Delivery not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding delivery", "")
+		}
+		return
+	}
+
+	var member models.TeamMember
+
+	err = findAdmin(ctx, r, userID, delivery.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return
+	}
+
+	count, err := database.DB.Collection("webhooks").CountDocuments(ctx, bson.M{"_id": delivery.WebhookId})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error finding webhook", "")
+		return
+	}
+	if count == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Webhook not found", "")
+		return
+	}
+
+	now := time.Now()
+	originalID := delivery.ID
+	redelivery := models.WebhookDelivery{
+		ID:            primitive.NewObjectID(),
+		WebhookId:     delivery.WebhookId,
+		TeamId:        delivery.TeamId,
+		Event:         delivery.Event,
+		Payload:       delivery.Payload,
+		Status:        "pending",
+		NextAttemptAt: &now,
+		RedeliveryOf:  &originalID,
+		CreatedAt:     now,
+	}
+
+	_, err = deliveryCollection.InsertOne(ctx, redelivery)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error queueing redelivery", "")
+		return
+	}
+
+	utils.RequestLogger(r).Info("Webhook redelivery queued")
+	utils.RespondWithJSON(w, http.StatusAccepted, "Redelivery queued", map[string]interface{}{"delivery": redelivery})
+}
+
+// findAdminWebhook loads the webhook in the route and checks the caller is an
+// admin of its team. It writes the error response itself and reports false
+// when the request should stop.
+func findAdminWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string) (models.Webhook, bool) {
+	var hook models.Webhook
+
+	vars := mux.Vars(r)
+	webhookID, err := primitive.ObjectIDFromHex(vars["webhookId"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Webhook ID", "")
+		return hook, false
+	}
+
+	err = database.DB.Collection("webhooks").FindOne(ctx, bson.M{"_id": webhookID}).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Webhook not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding webhook", "")
+		}
+		return hook, false
+	}
+
+	var member models.TeamMember
+	err = findAdmin(ctx, r, userID, hook.TeamId, &member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			utils.RespondWithError(w, http.StatusNotFound, "Member not found", "")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Error finding member", "")
+		}
+		return hook, false
+	}
+
+	return hook, true
+}
@@ -0,0 +1,272 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// DigestPeriods maps each digest frequency to the window one digest covers.
+var DigestPeriods = map[string]time.Duration{
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// digestItemLimit caps each section so a busy week still fits in one email.
+const digestItemLimit = 20
+
+// StartDigests sends due digests every interval until ctx is cancelled.
+func StartDigests(ctx context.Context, interval time.Duration) {
+	schedule(ctx, interval, RunDigests)
+}
+
+// RunDigests sends every digest whose period has elapsed by now.
+//
+// A user's digestSentAt watermark is moved to now before the email goes out,
+// with a filter on the value that was read, so after a restart or with two
+// schedulers running each window is only ever claimed once. If sending fails
+// the watermark is put back and the next run retries the same window.
+func RunDigests(ctx context.Context, now time.Time) {
+	userCollection := database.DB.Collection("users")
+
+	var due bson.A
+	for frequency, period := range DigestPeriods {
+		due = append(due, bson.M{"digest": frequency, "digestSentAt": bson.M{"$lte": now.Add(-period)}})
+	}
+
+	cursor, err := userCollection.Find(ctx, bson.M{"$or": due})
+	if err != nil {
+		utils.Logger.Warn("Failed to fetch digest subscribers", zap.Error(err))
+		return
+	}
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		utils.Logger.Warn("Failed to decode digest subscribers", zap.Error(err))
+		return
+	}
+
+	for _, user := range users {
+		since := *user.DigestSentAt
+
+		result, err := userCollection.UpdateOne(ctx, bson.M{"_id": user.ID, "digestSentAt": since}, bson.M{"$set": bson.M{"digestSentAt": now}})
+		if err != nil {
+			utils.Logger.Warn("Failed to claim digest", zap.String("userID", user.ID.Hex()), zap.Error(err))
+			continue
+		}
+		if result.MatchedCount == 0 {
+			// Another run claimed this window, or the user changed their settings.
+			continue
+		}
+
+		err = sendDigest(ctx, user, since, now)
+		if err == nil {
+			continue
+		}
+
+		utils.Logger.Warn("Failed to send digest", zap.String("userID", user.ID.Hex()), zap.Error(err))
+		_, err = userCollection.UpdateOne(ctx, bson.M{"_id": user.ID, "digestSentAt": now}, bson.M{"$set": bson.M{"digestSentAt": since}})
+		if err != nil {
+			utils.Logger.Warn("Failed to restore digest watermark", zap.String("userID", user.ID.Hex()), zap.Error(err))
+		}
+	}
+}
+
+type digest struct {
+	Assigned []models.Task
+	DueSoon  []models.Task
+	Overdue  []models.Task
+	Mentions []models.Message
+}
+
+func (d digest) empty() bool {
+	return len(d.Assigned) == 0 && len(d.DueSoon) == 0 && len(d.Overdue) == 0 && len(d.Mentions) == 0
+}
+
+// sendDigest emails user what happened in (since, now]. Nothing is sent when
+// there is nothing to report.
+func sendDigest(ctx context.Context, user models.User, since, now time.Time) error {
+	d, err := buildDigest(ctx, user, since, now)
+	if err != nil {
+		return err
+	}
+	if d.empty() {
+		return nil
+	}
+
+	return utils.SendEmail(user.Email, "Your "+user.Digest+" collab digest", renderDigest(user, d))
+}
+
+func buildDigest(ctx context.Context, user models.User, since, now time.Time) (digest, error) {
+	var d digest
+	userID := user.ID.Hex()
+	taskCollection := database.DB.Collection("tasks")
+
+	// Assignments come from the activity log, since tasks don't record when
+	// they were assigned.
+	cursor, err := database.DB.Collection("activity-log").Find(ctx, bson.M{
+		"action":       "Assign Task",
+		"targetUserID": userID,
+		"timestamp":    bson.M{"$gt": since, "$lte": now},
+	})
+	if err != nil {
+		return d, err
+	}
+
+	var logs []models.ActivityLog
+	if err = cursor.All(ctx, &logs); err != nil {
+		return d, err
+	}
+
+	var assignedIDs []primitive.ObjectID
+	for _, log := range logs {
+		if id, err := primitive.ObjectIDFromHex(log.TaskID); err == nil {
+			assignedIDs = append(assignedIDs, id)
+		}
+	}
+
+	open := bson.M{"assigned": user.ID, "completedAt": bson.M{"$exists": false}}
+	limit := options.Find().SetSort(bson.D{{Key: "dueDate", Value: 1}}).SetLimit(digestItemLimit)
+
+	if len(assignedIDs) > 0 {
+		// Only tasks still assigned to them; reassigned ones would be noise.
+		cursor, err = taskCollection.Find(ctx, bson.M{"_id": bson.M{"$in": assignedIDs}, "assigned": user.ID}, limit)
+		if err != nil {
+			return d, err
+		}
+		if err = cursor.All(ctx, &d.Assigned); err != nil {
+			return d, err
+		}
+	}
+
+	dueSoon := bson.M{"dueDate": bson.M{"$gte": now, "$lt": now.Add(DigestPeriods[user.Digest])}}
+	for key, value := range open {
+		dueSoon[key] = value
+	}
+	cursor, err = taskCollection.Find(ctx, dueSoon, limit)
+	if err != nil {
+		return d, err
+	}
+	if err = cursor.All(ctx, &d.DueSoon); err != nil {
+		return d, err
+	}
+
+	overdue := bson.M{"dueDate": bson.M{"$lt": now}}
+	for key, value := range open {
+		overdue[key] = value
+	}
+	cursor, err = taskCollection.Find(ctx, overdue, limit)
+	if err != nil {
+		return d, err
+	}
+	if err = cursor.All(ctx, &d.Overdue); err != nil {
+		return d, err
+	}
+
+	d.Mentions, err = findMentions(ctx, user, since, now)
+	if err != nil {
+		return d, err
+	}
+
+	return d, nil
+}
+
+// findMentions returns team messages from (since, now] that mention user as
+// @fullname or @ followed by the local part of their email.
+func findMentions(ctx context.Context, user models.User, since, now time.Time) ([]models.Message, error) {
+	var handles []string
+	if user.FullName != "" {
+		handles = append(handles, regexp.QuoteMeta(user.FullName))
+	}
+	if local, _, ok := strings.Cut(user.Email, "@"); ok && local != "" {
+		handles = append(handles, regexp.QuoteMeta(local))
+	}
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := database.DB.Collection("team-members").Find(ctx, bson.M{"user": user.ID.Hex()})
+	if err != nil {
+		return nil, err
+	}
+
+	var memberships []models.TeamMember
+	if err = cursor.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+	if len(memberships) == 0 {
+		return nil, nil
+	}
+
+	// Messages store their team as a hex string under "temaid".
+	teamIDs := make([]string, len(memberships))
+	for i, membership := range memberships {
+		teamIDs[i] = membership.TeamId.Hex()
+	}
+
+	pattern := `@(` + strings.Join(handles, "|") + `)\b`
+	cursor, err = database.DB.Collection("messages").Find(ctx, bson.M{
+		"temaid":    bson.M{"$in": teamIDs},
+		"user":      bson.M{"$ne": user.ID.Hex()},
+		"createdAt": bson.M{"$gt": since, "$lte": now},
+		"content":   primitive.Regex{Pattern: pattern, Options: "i"},
+	}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(digestItemLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func renderDigest(user models.User, d digest) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Hi %s,\n\nHere is your %s summary.\n", user.FullName, user.Digest)
+
+	section := func(heading string, tasks []models.Task) {
+		if len(tasks) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s (%d)\n", heading, len(tasks))
+		for _, task := range tasks {
+			line := "  - " + task.Title
+			if task.DueDate != nil {
+				line += " (due " + task.DueDate.Format("Mon 2 Jan") + ")"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	section("Newly assigned to you", d.Assigned)
+	section("Due soon", d.DueSoon)
+	section("Overdue", d.Overdue)
+
+	if len(d.Mentions) > 0 {
+		fmt.Fprintf(&b, "\nMentions (%d)\n", len(d.Mentions))
+		for _, message := range d.Mentions {
+			content := message.Content
+			if runes := []rune(content); len(runes) > 140 {
+				content = string(runes[:140]) + "…"
+			}
+			b.WriteString("  - " + content + "\n")
+		}
+	}
+
+	b.WriteString("\nYou can change how often you get this email in your profile settings.\n")
+	return b.String()
+}
@@ -0,0 +1,40 @@
+// Package jobs runs the background schedulers: recurring tasks, digests and
+// webhook deliveries.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Loboo34/collab-api/utils"
+)
+
+var running sync.WaitGroup
+
+// schedule calls run every interval, starting now, until ctx is cancelled.
+func schedule(ctx context.Context, interval time.Duration, run func(ctx context.Context, now time.Time)) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(ctx, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait waits for the schedulers to stop after their context is cancelled,
+// including any run in progress.
+func Wait(ctx context.Context) error {
+	return utils.WaitContext(ctx, &running)
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/metrics"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// maxCatchUp bounds how many missed occurrences of one task are created in a
+// single run, so a long outage doesn't flood a board with stale chores.
+const maxCatchUp = 10
+
+// StartRecurringTasks checks for due recurring tasks every interval until ctx is cancelled.
+func StartRecurringTasks(ctx context.Context, interval time.Duration) {
+	schedule(ctx, interval, RunRecurringTasks)
+}
+
+// RunRecurringTasks creates every instance that has come due by now.
+//
+// Each instance is upserted on (recurrenceOf, occurrence), which a unique index
+// backs, and nextRunAt is only advanced from the value that was read, so a run
+// interrupted by a restart, or two schedulers racing, never produce the same
+// occurrence twice.
+func RunRecurringTasks(ctx context.Context, now time.Time) {
+	taskCollection := database.DB.Collection("tasks")
+
+	cursor, err := taskCollection.Find(ctx, bson.M{
+		"recurrence": bson.M{"$exists": true},
+		"nextRunAt":  bson.M{"$lte": now},
+	})
+	if err != nil {
+		utils.Logger.Warn("Failed to fetch recurring tasks", zap.Error(err))
+		return
+	}
+
+	var templates []models.Task
+	if err = cursor.All(ctx, &templates); err != nil {
+		utils.Logger.Warn("Failed to decode recurring tasks", zap.Error(err))
+		return
+	}
+
+	for _, template := range templates {
+		if template.Recurrence == nil {
+			continue
+		}
+
+		for i := 0; i < maxCatchUp && template.NextRunAt != nil && !template.NextRunAt.After(now); i++ {
+			occurrence := *template.NextRunAt
+
+			if err := createOccurrence(ctx, template, occurrence); err != nil {
+				utils.Logger.Warn("Failed to create recurring task instance", zap.String("taskID", template.ID.Hex()), zap.Error(err))
+				break
+			}
+
+			update := bson.M{"$unset": bson.M{"nextRunAt": ""}}
+			template.NextRunAt = nil
+			if next, ok := services.NextOccurrence(*template.Recurrence, occurrence); ok {
+				update = bson.M{"$set": bson.M{"nextRunAt": next}}
+				template.NextRunAt = &next
+			}
+
+			result, err := taskCollection.UpdateOne(ctx, bson.M{"_id": template.ID, "nextRunAt": occurrence}, update)
+			if err != nil {
+				utils.Logger.Warn("Failed to advance recurring task", zap.String("taskID", template.ID.Hex()), zap.Error(err))
+				break
+			}
+			if result.MatchedCount == 0 {
+				// Someone else advanced or edited the rule since we read it.
+				break
+			}
+		}
+	}
+}
+
+func createOccurrence(ctx context.Context, template models.Task, occurrence time.Time) error {
+	var project models.Project
+	err := database.DB.Collection("projects").FindOne(ctx, bson.M{"_id": template.ProjectId}).Decode(&project)
+	if err != nil {
+		return err
+	}
+
+	status := services.Workflow(project)[0]
+	position, err := services.NextPosition(ctx, template.ProjectId, status)
+	if err != nil {
+		return err
+	}
+
+	templateID := template.ID
+	instance := models.Task{
+		ID:           primitive.NewObjectID(),
+		Title:        template.Title,
+		Description:  template.Description,
+		Status:       status,
+		Position:     position,
+		AssignedTo:   template.AssignedTo,
+		TeamId:       template.TeamId,
+		ProjectId:    template.ProjectId,
+		SprintId:     template.SprintId,
+		DueDate:      &occurrence,
+		RecurrenceOf: &templateID,
+		Occurrence:   &occurrence,
+		CreatedAt:    time.Now(),
+		CreatedBy:    template.CreatedBy,
+	}
+
+	result, err := database.DB.Collection("tasks").UpdateOne(
+		ctx,
+		bson.M{"recurrenceOf": template.ID, "occurrence": occurrence},
+		bson.M{"$setOnInsert": instance},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another scheduler inserted it between our match and insert.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.UpsertedCount == 0 {
+		return nil
+	}
+	metrics.TasksCreated.Inc()
+
+	_, err = database.DB.Collection("projects").UpdateOne(ctx, bson.M{"_id": template.ProjectId}, bson.M{"$addToSet": bson.M{"tasks": instance.ID}})
+	if err != nil {
+		return err
+	}
+
+	utils.Log(
+		ctx,
+		template.CreatedBy,
+		template.TeamId.Hex(),
+		template.ProjectId.Hex(),
+		instance.ID.Hex(),
+		"Create Recurring Task",
+		"Scheduler created '"+instance.Title+"' due "+occurrence.Format(time.RFC3339)+" from recurring task "+template.ID.Hex())
+
+	return nil
+}
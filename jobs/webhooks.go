@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/metrics"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+	"github.com/Loboo34/collab-api/webhooks"
+)
+
+const (
+	// deliveryLease keeps a claimed delivery away from other workers while it
+	// is being sent. A worker that dies mid-send leaves it to be retried once
+	// the lease runs out.
+	deliveryLease = time.Minute
+
+	// deliveryBatch bounds how many deliveries one run sends.
+	deliveryBatch = 100
+)
+
+// StartWebhookDeliveries sends queued webhook deliveries every interval until
+// ctx is cancelled.
+func StartWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	schedule(ctx, interval, RunWebhookDeliveries)
+}
+
+// RunWebhookDeliveries sends every pending delivery that is due by now. The
+// lease and retry times count from when each delivery is claimed and sent,
+// since a batch of slow receivers can take minutes.
+func RunWebhookDeliveries(ctx context.Context, now time.Time) {
+	deliveryCollection := database.DB.Collection("webhook-deliveries")
+
+	for i := 0; i < deliveryBatch; i++ {
+		var delivery models.WebhookDelivery
+		err := deliveryCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"status": "pending", "nextAttemptAt": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"nextAttemptAt": time.Now().Add(deliveryLease)}, "$inc": bson.M{"attempts": 1}},
+			options.FindOneAndUpdate().SetSort(bson.M{"nextAttemptAt": 1}).SetReturnDocument(options.After),
+		).Decode(&delivery)
+		if err == mongo.ErrNoDocuments {
+			return
+		}
+		if err != nil {
+			utils.Logger.Warn("Failed to claim webhook delivery", zap.Error(err))
+			return
+		}
+
+		update := deliver(ctx, delivery)
+		recordDeliveryOutcome(update)
+		if _, err := deliveryCollection.UpdateOne(ctx, bson.M{"_id": delivery.ID}, update); err != nil {
+			utils.Logger.Warn("Failed to record webhook delivery", zap.String("deliveryID", delivery.ID.Hex()), zap.Error(err))
+		}
+	}
+}
+
+// recordDeliveryOutcome counts a delivery attempt by the status it leaves
+// the delivery in.
+func recordDeliveryOutcome(update bson.M) {
+	outcome := "retry"
+	if set, ok := update["$set"].(bson.M); ok {
+		if status, ok := set["status"].(string); ok {
+			outcome = status
+		}
+	}
+	metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+}
+
+// deliver sends one claimed delivery and returns the update recording the outcome.
+func deliver(ctx context.Context, delivery models.WebhookDelivery) bson.M {
+	failed := func(message string) bson.M {
+		return bson.M{
+			"$set":   bson.M{"status": "failed", "lastError": message},
+			"$unset": bson.M{"nextAttemptAt": ""},
+		}
+	}
+
+	var hook models.Webhook
+	err := database.DB.Collection("webhooks").FindOne(ctx, bson.M{"_id": delivery.WebhookId}).Decode(&hook)
+	if err == mongo.ErrNoDocuments {
+		return failed("webhook was deleted")
+	}
+	if err != nil {
+		return bson.M{"$set": bson.M{"nextAttemptAt": time.Now().Add(webhooks.Backoff(delivery.Attempts)), "lastError": err.Error()}}
+	}
+	if !hook.Active {
+		return failed("webhook is disabled")
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	statusCode, err := webhooks.Send(sendCtx, hook, delivery, time.Now())
+	if err == nil {
+		return bson.M{
+			"$set":   bson.M{"status": "delivered", "deliveredAt": time.Now(), "lastStatusCode": statusCode},
+			"$unset": bson.M{"nextAttemptAt": "", "lastError": ""},
+		}
+	}
+
+	utils.Logger.Info("Webhook delivery failed", zap.String("deliveryID", delivery.ID.Hex()), zap.Int("attempt", delivery.Attempts), zap.Error(err))
+
+	if delivery.Attempts >= webhooks.MaxAttempts {
+		update := failed(err.Error())
+		update["$set"].(bson.M)["lastStatusCode"] = statusCode
+		return update
+	}
+
+	return bson.M{"$set": bson.M{
+		"nextAttemptAt":  time.Now().Add(webhooks.Backoff(delivery.Attempts)),
+		"lastStatusCode": statusCode,
+		"lastError":      err.Error(),
+	}}
+}
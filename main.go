@@ -1,58 +1,147 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 
+	"github.com/Loboo34/collab-api/config"
 	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
 	"github.com/Loboo34/collab-api/handlers"
+	"github.com/Loboo34/collab-api/jobs"
 	"github.com/Loboo34/collab-api/middleware"
+	"github.com/Loboo34/collab-api/migrations"
+	"github.com/Loboo34/collab-api/notifications"
 	"github.com/Loboo34/collab-api/utils"
+	"github.com/Loboo34/collab-api/tracing"
+	"github.com/Loboo34/collab-api/webhooks"
 )
 
-func init() {
-	err := godotenv.Load()
+func main() {
+	cfg, command, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatal("Failed to load env")
+		log.Fatal(err)
+	}
+
+	if len(command) > 0 {
+		if command[0] != "migrate" {
+			log.Fatal("Unknown command: " + strings.Join(command, " "))
+		}
+		database.ConnectDB(cfg.MongoURI.Value(), cfg.MongoDatabase)
+		utils.InitLogger()
+		defer utils.SyncLogger()
+		if err := migrate(command[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-}
 
-func main() {
 	r := mux.NewRouter()
 
-	r.Use(middleware.Cors())
+	r.Use(otelmux.Middleware(tracing.ServiceName))
+	r.Use(middleware.RequestLogging())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.Cors(cfg.AllowedOrigins))
+	r.Use(middleware.RateLimit(middleware.NewMemoryRateStore()))
 
-	db := database.ConnectDB()
+	db := database.ConnectDB(cfg.MongoURI.Value(), cfg.MongoDatabase)
 	fmt.Println("DbName:", db.Name())
 	utils.InitLogger()
+	defer utils.SyncLogger()
+	utils.Logger.Info("Loaded configuration", zap.Stringer("config", cfg))
+
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := startupMigrations(cfg.MigrateOnStart); err != nil {
+		log.Fatal(err)
+	}
 
-	if err := utils.InitJWT(); err != nil {
+	if err := utils.InitJWT(cfg.JWTSecret.Value()); err != nil {
 		log.Fatal("Failed to initialize JWT:", err)
 	}
+	utils.InitEmail(cfg.SMTP)
+	utils.SetTrustProxy(cfg.TrustProxy)
+	handlers.Configure(cfg)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobs.StartRecurringTasks(jobsCtx, time.Minute)
+	jobs.StartDigests(jobsCtx, 15*time.Minute)
+	notifications.Register()
+	webhooks.Register()
+	jobs.StartWebhookDeliveries(jobsCtx, 10*time.Second)
 
 	r.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
     w.WriteHeader(http.StatusOK)
 })
 
+	r.HandleFunc("/metrics", middleware.MetricsHandler(cfg.MetricsToken.Value())).Methods("GET")
+	r.HandleFunc("/healthz", handlers.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", handlers.Readyz).Methods("GET")
+
 	//handlers
 	//auth
 	r.HandleFunc("/auth/register", handlers.RegisterUser).Methods("POST")
 	r.HandleFunc("/auth/login", handlers.LoginUser).Methods("POST")
+	r.HandleFunc("/auth/2fa/verify", handlers.VerifyMFA).Methods("POST")
+	r.HandleFunc("/auth/oidc/providers", handlers.GetOIDCProviders).Methods("GET")
+	r.HandleFunc("/auth/oidc/{provider}/login", handlers.OIDCLogin).Methods("GET")
+	r.HandleFunc("/auth/oidc/{provider}/callback", handlers.OIDCCallback).Methods("GET")
+
+	// me
+	r.HandleFunc("/me", middleware.CheckAuth(handlers.Profile)).Methods("GET")
+	r.HandleFunc("/me", middleware.CheckAuth(handlers.UpdateProfile)).Methods("PUT")
+	r.HandleFunc("/me/password", middleware.CheckAuth(handlers.ChangePassword)).Methods("PUT")
+	r.HandleFunc("/me/identities/{provider}", middleware.CheckAuth(handlers.LinkOIDCIdentity)).Methods("POST")
+	r.HandleFunc("/me/tasks", middleware.CheckAuth(handlers.GetMyTasks)).Methods("GET")
+	r.HandleFunc("/me/teams", middleware.CheckAuth(handlers.GetMyTeams)).Methods("GET")
+	r.HandleFunc("/me/notifications/preferences", middleware.CheckAuth(handlers.GetNotificationPreferences)).Methods("GET")
+	r.HandleFunc("/me/notifications/preferences", middleware.CheckAuth(handlers.UpdateNotificationPreferences)).Methods("PUT")
+	r.HandleFunc("/me/digest", middleware.CheckAuth(handlers.UpdateDigest)).Methods("PUT")
+	r.HandleFunc("/me/2fa/enroll", middleware.CheckEnrollAuth(handlers.EnrollTOTP)).Methods("POST")
+	r.HandleFunc("/me/2fa/confirm", middleware.CheckEnrollAuth(handlers.ConfirmTOTP)).Methods("POST")
+	r.HandleFunc("/me/2fa/recovery-codes", middleware.CheckAuth(handlers.RegenerateRecoveryCodes)).Methods("POST")
+	r.HandleFunc("/me/2fa", middleware.CheckAuth(handlers.DisableTOTP)).Methods("DELETE")
+	r.HandleFunc("/me/tokens", middleware.CheckAuth(handlers.CreateToken)).Methods("POST")
+	r.HandleFunc("/me/tokens", middleware.CheckAuth(handlers.GetTokens)).Methods("GET")
+	r.HandleFunc("/me/tokens/{tokenId}", middleware.CheckAuth(handlers.RevokeToken)).Methods("DELETE")
+
+	// notifications
+	r.HandleFunc("/notifications", middleware.CheckAuth(handlers.GetNotifications)).Methods("GET")
+	r.HandleFunc("/notifications/unread-count", middleware.CheckAuth(handlers.GetUnreadCount)).Methods("GET")
+	r.HandleFunc("/notifications/read-all", middleware.CheckAuth(handlers.MarkAllNotificationsRead)).Methods("PUT")
+	r.HandleFunc("/notifications/{notificationId}/read", middleware.CheckAuth(handlers.MarkNotificationRead)).Methods("PUT")
 
 	// teams
 	r.HandleFunc("/team/create", middleware.CheckAuth(handlers.CreateTeam)).Methods("Post")
+	r.HandleFunc("/team/import", middleware.CheckAuth(handlers.ImportTeam)).Methods("Post")
 	r.HandleFunc("/team/{teamId}/update", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.UpdateTeam))).Methods("PUT")
 	r.HandleFunc("/team/invite", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.InviteMember))).Methods("Post")
 	r.HandleFunc("/invite/accept", middleware.CheckAuth(handlers.AcceptInvite)).Methods("Post")
 	r.HandleFunc("/invite/Decline", middleware.CheckAuth(handlers.DeclineInvite)).Methods("Post")
 	r.HandleFunc("/teams", middleware.CheckAuth(handlers.GetTeams)).Methods("GET")
 	r.HandleFunc("/team/{teamId}/members", middleware.CheckAuth(handlers.GetTeamMembers)).Methods("Get")
+	r.HandleFunc("/team/{teamId}/stats", middleware.CheckAuth(handlers.GetTeamStats)).Methods("Get")
+	r.HandleFunc("/team/{teamId}/export", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.ExportTeam))).Methods("Get")
 	r.HandleFunc("/team/{teamId}/", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.ChangeRole)))
+	r.HandleFunc("/team/{teamId}/require-2fa", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.SetTeamRequire2FA))).Methods("Put")
+	r.HandleFunc("/team/{teamId}/unlock", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.UnlockMember))).Methods("Post")
 	r.HandleFunc("/team/{teamId}/remove", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.RemoveMember))).Methods("Delete")
 	r.HandleFunc("/team/{teamId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.DeleteTeam))).Methods("Delete")
 
@@ -62,22 +151,165 @@ func main() {
 	r.HandleFunc("/project/{projectId}/update", middleware.CheckAuth(middleware.CheckRole("Admin",handlers.UpdateProject))).Methods("Put")
 	r.HandleFunc("/team/{teamId}/projects", middleware.CheckAuth(handlers.GetProjects)).Methods("Get")
 	r.HandleFunc("/project/{projectId}", middleware.CheckAuth(handlers.GetProject)).Methods("Get")
+	r.HandleFunc("/project/{projectId}/board", middleware.CheckAuth(handlers.GetBoard)).Methods("Get")
+	r.HandleFunc("/project/{projectId}/stats", middleware.CheckAuth(handlers.GetProjectStats)).Methods("Get")
 	r.HandleFunc("/project/{projectId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.DeleteProject))).Methods("Delete")
 
+	// webhooks
+	r.HandleFunc("/team/{teamId}/webhooks", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.CreateWebhook))).Methods("Post")
+	r.HandleFunc("/team/{teamId}/webhooks", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.GetWebhooks))).Methods("Get")
+	r.HandleFunc("/webhook/{webhookId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.DeleteWebhook))).Methods("Delete")
+	r.HandleFunc("/webhook/{webhookId}/deliveries", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.GetWebhookDeliveries))).Methods("Get")
+	r.HandleFunc("/delivery/{deliveryId}/redeliver", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.RedeliverWebhook))).Methods("Post")
+
+	// service accounts
+	r.HandleFunc("/team/{teamId}/service-accounts", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.CreateServiceAccount))).Methods("Post")
+	r.HandleFunc("/team/{teamId}/service-accounts", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.GetServiceAccounts))).Methods("Get")
+	r.HandleFunc("/service-account/{accountId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.DeleteServiceAccount))).Methods("Delete")
+	r.HandleFunc("/service-account/{accountId}/tokens", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.CreateServiceAccountToken))).Methods("Post")
+	r.HandleFunc("/service-account/{accountId}/tokens/{tokenId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.RevokeServiceAccountToken))).Methods("Delete")
+
+	// inbound integrations
+	r.HandleFunc("/project/{projectId}/integrations", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.CreateInboundHook))).Methods("Post")
+	r.HandleFunc("/project/{projectId}/integrations", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.GetInboundHooks))).Methods("Get")
+	r.HandleFunc("/integration/{integrationId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.DeleteInboundHook))).Methods("Delete")
+	r.HandleFunc("/hooks/inbound/{token}", handlers.ReceiveInboundHook).Methods("Post")
+
+	// search
+	r.HandleFunc("/search", middleware.CheckAuth(handlers.Search)).Methods("Get")
+
+	// templates
+	r.HandleFunc("/project/{projectId}/template", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.SaveProjectTemplate))).Methods("Post")
+	r.HandleFunc("/team/{teamId}/templates", middleware.CheckAuth(handlers.GetTemplates)).Methods("Get")
+	r.HandleFunc("/template/{templateId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.DeleteTemplate))).Methods("Delete")
+	r.HandleFunc("/template/{templateId}/project", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.CreateProjectFromTemplate))).Methods("Post")
+
+	// sprints
+	r.HandleFunc("/project/{projectId}/sprints", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.CreateSprint))).Methods("Post")
+	r.HandleFunc("/project/{projectId}/sprints", middleware.CheckAuth(handlers.GetSprints)).Methods("Get")
+	r.HandleFunc("/sprint/{sprintId}", middleware.CheckAuth(handlers.GetSprint)).Methods("Get")
+	r.HandleFunc("/sprint/{sprintId}", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.UpdateSprint))).Methods("Put")
+	r.HandleFunc("/sprint/{sprintId}/close", middleware.CheckAuth(middleware.CheckRole("Admin", handlers.CloseSprint))).Methods("Post")
+
 	// tasks
 	r.HandleFunc("/task/create", middleware.CheckAuth(handlers.CreateTask)).Methods("Post")
 	r.HandleFunc("/task/{taskId}/update", middleware.CheckAuth(handlers.UpdateTask)).Methods("Put")
 	r.HandleFunc("/task/{taskId}/assign", middleware.CheckAuth(handlers.AssignTo)).Methods("Post")
 	r.HandleFunc("/task/{taskId}/status", middleware.CheckAuth(handlers.Status)).Methods("Put")
+	r.HandleFunc("/task/{taskId}/move", middleware.CheckAuth(handlers.MoveTask)).Methods("Put")
+	r.HandleFunc("/task/{taskId}/recurrence", middleware.CheckAuth(handlers.SetRecurrence)).Methods("Put")
+	r.HandleFunc("/task/{taskId}/sprint", middleware.CheckAuth(handlers.AssignSprint)).Methods("Put")
 	r.HandleFunc("/project/{projectId}/tasks", middleware.CheckAuth(handlers.GetTasks)).Methods("Get")
 	r.HandleFunc("/task/{taskId}", middleware.CheckAuth(handlers.GetTask)).Methods("Get")
 	r.HandleFunc("/task/{taskId}", middleware.CheckAuth(handlers.DeleteTask)).Methods("Delete")
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	port := strconv.Itoa(cfg.Port)
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	stop, cancelStop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancelStop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Println("Server is running at http://localhost:" + port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		utils.Logger.Error("Server stopped", zap.Error(err))
+	case <-stop.Done():
+		utils.Logger.Info("Shutting down")
+	}
+
+	shutdown(server, stopJobs, cfg.ShutdownTimeout)
+}
+
+// migrate runs "migrate" (apply pending migrations) and "migrate status".
+func migrate(args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	switch strings.Join(args, " ") {
+	case "":
+		return migrations.Run(ctx)
+	case "status":
+		statuses, err := migrations.Statuses(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%3d  %-32s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return errors.New("Usage: migrate [status]")
+	}
+}
+
+// startupMigrations applies pending migrations, or when that is turned off,
+// refuses to start a server whose database hasn't been migrated.
+func startupMigrations(migrateOnStart bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if migrateOnStart {
+		if err := migrations.Run(ctx); err != nil {
+			return fmt.Errorf("Failed to migrate: %w", err)
+		}
+		return nil
+	}
+
+	statuses, err := migrations.Statuses(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to check migrations: %w", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			return fmt.Errorf("Migration %d (%s) is pending; run the migrate command first", s.Version, s.Name)
+		}
+	}
+	return database.EnsureIndexes(ctx)
+}
+
+// shutdown stops taking requests and lets in-flight ones finish, then stops
+// the schedulers and waits for background work, so nothing is still writing
+// when the Mongo client disconnects.
+func shutdown(server *http.Server, stopJobs context.CancelFunc, timeout time.Duration) {
+	handlers.MarkShuttingDown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		utils.Logger.Warn("Failed to drain requests", zap.Error(err))
+	}
+
+	stopJobs()
+	if err := jobs.Wait(ctx); err != nil {
+		utils.Logger.Warn("Schedulers did not stop in time", zap.Error(err))
+	}
+	if err := events.Wait(ctx); err != nil {
+		utils.Logger.Warn("Event subscribers did not finish in time", zap.Error(err))
+	}
+	if err := utils.WaitForActivityLogs(ctx); err != nil {
+		utils.Logger.Warn("Activity log writes did not finish in time", zap.Error(err))
 	}
 
-	fmt.Println("Server is running at http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	if err := database.Client.Disconnect(ctx); err != nil {
+		utils.Logger.Warn("Failed to disconnect from Mongo", zap.Error(err))
+	}
+	utils.Logger.Info("Shutdown complete")
 }
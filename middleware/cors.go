@@ -2,22 +2,13 @@ package middleware
 
 import (
 	"net/http"
-	"os"
-	"strings"
 
 	"github.com/gorilla/mux"
 )
 
-func Cors() mux.MiddlewareFunc {
+func Cors(allowedOrigins []string) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get allowed origins from environment variable
-			allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-			if allowedOrigins == "" {
-				// Default to localhost for development
-				allowedOrigins = "http://localhost:3000,http://localhost:5173"
-			}
-
 			origin := r.Header.Get("Origin")
 
 			// Check if origin is allowed
@@ -28,9 +19,9 @@ func Cors() mux.MiddlewareFunc {
 
 			// Set other CORS headers
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
 			w.Header().Set("Access-Control-Max-Age", "86400")
-			w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, Link, RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, X-Request-ID")
 
 			// Handle preflight requests
 			if r.Method == http.MethodOptions {
@@ -44,10 +35,9 @@ func Cors() mux.MiddlewareFunc {
 }
 
 // Helper function to check if origin is allowed
-func isOriginAllowed(origin, allowedOrigins string) bool {
-	origins := strings.Split(allowedOrigins, ",")
-	for _, allowed := range origins {
-		if strings.TrimSpace(allowed) == origin {
+func isOriginAllowed(origin string, allowedOrigins []string) bool {
+	for _, allowed := range allowedOrigins {
+		if allowed == origin {
 			return true
 		}
 	}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/utils"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// redactedParams are query parameters whose values never reach the logs.
+var redactedParams = map[string]bool{
+	"token":         true,
+	"code":          true,
+	"state":         true,
+	"password":      true,
+	"secret":        true,
+	"key":           true,
+	"access_token":  true,
+	"client_secret": true,
+}
+
+// requestInfo is filled in by handlers further down the chain, which only
+// see copies of the request, and read back once the response is written.
+type requestInfo struct {
+	userID string
+}
+
+type requestInfoKey struct{}
+
+// setRequestUser records the authenticated user for the access log.
+func setRequestUser(r *http.Request, userID string) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the connection, for handlers
+// that flush or change their deadlines.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// RequestLogging gives every request an ID, echoed in X-Request-ID, and a
+// logger carrying it for handlers to use through utils.RequestLogger. When
+// the request finishes it writes one access log line. Paths are logged by
+// route template and secrets in the query string are redacted, so tokens in
+// URLs never end up in the logs.
+func RequestLogging() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+
+			logger := utils.Logger.With(
+				zap.String("request_id", requestID),
+				zap.String("method", r.Method),
+				zap.String("route", route),
+			)
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				logger = logger.With(zap.String("trace_id", span.TraceID().String()))
+			}
+
+			info := &requestInfo{}
+			ctx := utils.WithLogger(r.Context(), logger)
+			ctx = context.WithValue(ctx, requestInfoKey{}, info)
+
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+
+			fields := []zap.Field{
+				zap.Int("status", recorder.status),
+				zap.Duration("latency", time.Since(start)),
+				zap.Int("bytes", recorder.bytes),
+				zap.String("remote_ip", utils.ClientIP(r)),
+			}
+			if query := redactQuery(r.URL.Query()); query != "" {
+				fields = append(fields, zap.String("query", query))
+			}
+			if info.userID != "" {
+				fields = append(fields, zap.String("user_id", info.userID))
+			}
+
+			switch {
+			case recorder.status >= 500:
+				logger.Error("request", fields...)
+			case recorder.status >= 400:
+				logger.Warn("request", fields...)
+			default:
+				logger.Info("request", fields...)
+			}
+		})
+	}
+}
+
+// validRequestID accepts IDs from clients or proxies only when they are
+// short and plain, so they can't be used to inject into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func redactQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	for name := range query {
+		if redactedParams[strings.ToLower(name)] {
+			query[name] = []string{"REDACTED"}
+		}
+	}
+	return query.Encode()
+}
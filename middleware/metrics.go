@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Loboo34/collab-api/metrics"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// Metrics counts requests and records their latency by route template, so
+// paths with IDs in them don't each become their own series.
+func Metrics() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			metrics.ObserveHTTP(r.Method, route, recorder.status, time.Since(start))
+		})
+	}
+}
+
+// MetricsHandler serves the Prometheus metrics. When token is set, scrapers
+// have to send it as a bearer token.
+func MetricsHandler(token string) http.HandlerFunc {
+	handler := promhttp.Handler()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			given, err := utils.ExtractToken(r)
+			if err != nil || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Invalid metrics token", "")
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}
+}
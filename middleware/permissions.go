@@ -4,32 +4,35 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
 	"github.com/Loboo34/collab-api/utils"
 )
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 func CheckAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		token, err := utils.ExtractToken(r)
 		if err != nil {
-			utils.Logger.Warn("Auth token Not found: " + err.Error())
+			utils.RequestLogger(r).Warn("Auth token Not found: " + err.Error())
 			utils.RespondWithError(w, http.StatusUnauthorized, "Missing Auth token", "")
 			return
 		}
 
-		utils.Logger.Info("Token: " + token[:min(50, len(token))] + "...")
+		if services.IsAPIToken(token) {
+			checkAPIToken(w, r, token, next)
+			return
+		}
 
 		claims, err := utils.ValidateJWT(token)
 		if err != nil {
-			utils.Logger.Warn("JWT validation failed" + err.Error())
+			utils.RequestLogger(r).Warn("JWT validation failed" + err.Error())
 			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Auth Token", "")
 			return
 		}
@@ -45,14 +48,87 @@ func CheckAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "claims", claims)
-		ctx = context.WithValue(ctx, "userID", userID)
+		ctx := authenticated(r, userID)
+		ctx = context.WithValue(ctx, "claims", claims)
 		ctx = context.WithValue(ctx, "role", role)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// authenticated returns the request context for userID: it carries the ID
+// for utils.GetUserID and a logger tagged with it, and the ID is added to the
+// access log.
+func authenticated(r *http.Request, userID string) context.Context {
+	setRequestUser(r, userID)
+	ctx := utils.WithLogger(r.Context(), utils.RequestLogger(r).With(zap.String("user_id", userID)))
+	return context.WithValue(ctx, "userID", userID)
+}
+
+// checkAPIToken authenticates a request made with a personal access token or
+// a service account token. The token acts with its owner's role, the same one
+// a login would put in the JWT, but only Admin if it has the admin scope.
+// Tokens without the write scope can only read.
+func checkAPIToken(w http.ResponseWriter, r *http.Request, token string, next http.HandlerFunc) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// The rate limiter has usually resolved it already.
+	var err error
+	apiToken, resolved := r.Context().Value(resolvedTokenKey{}).(models.APIToken)
+	if !resolved {
+		apiToken, err = services.ResolveAPIToken(ctx, token, time.Now())
+	}
+	if err != nil {
+		if err != services.ErrInvalidAPIToken {
+			utils.RequestLogger(r).Warn("API token lookup failed: " + err.Error())
+		}
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Auth Token", "")
+		return
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+	if !safe && !apiToken.HasScope(models.ScopeWrite) {
+		utils.RespondWithError(w, http.StatusForbidden, "Token is missing the write scope", "")
+		return
+	}
+
+	var member models.TeamMember
+	role := ""
+	err = database.DB.Collection("team-members").FindOne(ctx, bson.M{"user": apiToken.UserID}).Decode(&member)
+	if err == nil {
+		role = member.Role
+	}
+	if strings.EqualFold(role, "Admin") && !apiToken.HasScope(models.ScopeAdmin) {
+		role = "Member"
+	}
+
+	reqCtx := authenticated(r, apiToken.UserID)
+	reqCtx = context.WithValue(reqCtx, "token", apiToken)
+	reqCtx = context.WithValue(reqCtx, "role", role)
+
+	next.ServeHTTP(w, r.WithContext(reqCtx))
+}
+
+// CheckEnrollAuth is CheckAuth for the 2FA enrollment endpoints, which also
+// accept the enrollment token a login returns when a team requires 2FA the
+// user hasn't set up yet.
+func CheckEnrollAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := utils.ExtractToken(r)
+		if err == nil {
+			if userID, err := utils.ValidatePurposeJWT(token, utils.PurposeMFAEnroll); err == nil {
+				ctx := authenticated(r, userID)
+				ctx = context.WithValue(ctx, "purpose", utils.PurposeMFAEnroll)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		CheckAuth(next)(w, r)
+	}
+}
+
 func CheckRole(userRole string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Loboo34/collab-api/services"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// Limit allows Requests per Per, with bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// RatePolicy applies a limit to the routes whose path template matches
+// Route: exactly, or as a prefix when Route ends in "/". Methods narrows it
+// to some methods; empty means all.
+type RatePolicy struct {
+	Name    string
+	Route   string
+	Methods []string
+	Limit   Limit
+}
+
+// RatePolicies are checked in order and the first match wins. Requests that
+// match none fall back to DefaultReadLimit or DefaultWriteLimit.
+var RatePolicies = []RatePolicy{
+	{Name: "auth", Route: "/auth/", Limit: Limit{Requests: 10, Per: time.Minute}},
+	{Name: "invite", Route: "/team/invite", Limit: Limit{Requests: 20, Per: time.Hour}},
+	{Name: "inbound", Route: "/hooks/", Limit: Limit{Requests: 120, Per: time.Minute}},
+	{Name: "search", Route: "/search", Limit: Limit{Requests: 60, Per: time.Minute}},
+	// Exports and imports share one bucket; both are heavy.
+	{Name: "archive", Route: "/team/{teamId}/export", Limit: Limit{Requests: 10, Per: time.Hour}},
+	{Name: "archive", Route: "/team/import", Limit: Limit{Requests: 10, Per: time.Hour}},
+}
+
+var (
+	DefaultReadLimit  = RatePolicy{Name: "read", Limit: Limit{Requests: 300, Per: time.Minute}}
+	DefaultWriteLimit = RatePolicy{Name: "write", Limit: Limit{Requests: 60, Per: time.Minute}}
+)
+
+// RateResult is the outcome of taking one request from a bucket.
+type RateResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, when denied
+}
+
+// RateLimitStore keeps the token buckets. MemoryRateStore is enough for a
+// single instance; several instances need a shared one.
+type RateLimitStore interface {
+	Take(key string, limit Limit, now time.Time) RateResult
+}
+
+// RateLimit throttles every request by the policy for its route, with one
+// bucket per policy and caller. Callers are identified by user when the
+// request carries a valid credential and by client IP otherwise.
+func RateLimit(store RateLimitStore) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			policy := policyFor(r)
+			now := time.Now()
+			key, token := rateKey(r)
+			result := store.Take(policy.Name+":"+key, policy.Limit, now)
+
+			// API tokens are only looked up once their bucket allows the
+			// request, so throttled ones cost no database round trip. Tokens
+			// that don't resolve are charged to the client IP as well, or
+			// made-up ones would each get a fresh bucket.
+			if result.Allowed && token != "" {
+				var resolved bool
+				if r, resolved = resolveToken(r, token); !resolved {
+					result = store.Take(policy.Name+":ip:"+utils.ClientIP(r), policy.Limit, now)
+				}
+			}
+
+			window := int(policy.Limit.Per.Seconds())
+			w.Header().Set("RateLimit-Policy", strconv.Itoa(policy.Limit.Requests)+";w="+strconv.Itoa(window))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Limit.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				utils.RespondWithError(w, http.StatusTooManyRequests, "Too many requests, slow down", "")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func policyFor(r *http.Request) RatePolicy {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			path = template
+		}
+	}
+
+	for _, policy := range RatePolicies {
+		matches := path == policy.Route || (strings.HasSuffix(policy.Route, "/") && strings.HasPrefix(path, policy.Route))
+		if !matches {
+			continue
+		}
+		if len(policy.Methods) == 0 {
+			return policy
+		}
+		for _, method := range policy.Methods {
+			if strings.EqualFold(method, r.Method) {
+				return policy
+			}
+		}
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return DefaultReadLimit
+	}
+	return DefaultWriteLimit
+}
+
+// rateKey identifies the caller: the user ID if one is already on the
+// context or in a valid JWT, a hash of the API token, and the client IP
+// otherwise, so made-up JWTs are limited by IP and then rejected by
+// CheckAuth. For API tokens it also returns the token, which still has to be
+// resolved.
+func rateKey(r *http.Request) (string, string) {
+	if userID, err := utils.GetUserID(r); err == nil {
+		return "user:" + userID, ""
+	}
+
+	if token, err := utils.ExtractToken(r); err == nil {
+		if services.IsAPIToken(token) {
+			sum := sha256.Sum256([]byte(token))
+			return "token:" + hex.EncodeToString(sum[:8]), token
+		} else if claims, err := utils.ValidateJWT(token); err == nil {
+			if userID, ok := claims["id"].(string); ok && userID != "" {
+				return "user:" + userID, ""
+			}
+		}
+	}
+
+	return "ip:" + utils.ClientIP(r), ""
+}
+
+// resolveToken looks up an API token and reports whether it is valid. The
+// limiter runs before CheckAuth, so a resolved token is put on the returned
+// request for CheckAuth to reuse.
+func resolveToken(r *http.Request, token string) (*http.Request, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	apiToken, err := services.ResolveAPIToken(ctx, token, time.Now())
+	if err != nil {
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), resolvedTokenKey{}, apiToken)), true
+}
+
+// resolvedTokenKey holds the models.APIToken resolveToken resolved.
+type resolvedTokenKey struct{}
+
+// MemoryRateStore keeps token buckets in process memory.
+type MemoryRateStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will be full again if left alone
+}
+
+func NewMemoryRateStore() *MemoryRateStore {
+	return &MemoryRateStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryRateStore) Take(key string, limit Limit, now time.Time) RateResult {
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Per.Seconds() // tokens per second
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	result := RateResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result
+}
+
+// sweep drops buckets that have refilled, at most once a minute, so idle
+// callers don't accumulate. A full bucket is the same as no bucket.
+func (s *MemoryRateStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,161 @@
+// Package migrations brings the database up to date: data fixes and indexes
+// that can't simply be (re)created on every start. Applied versions are
+// recorded in the schema-migrations collection, so each one runs once.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// Migration is one step. Up may be interrupted before the version is
+// recorded, so it must be safe to run again.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// Applied is the record of a migration that has run.
+type Applied struct {
+	Version   int       `bson:"_id" json:"version"`
+	Name      string    `bson:"name" json:"name"`
+	AppliedAt time.Time `bson:"appliedAt" json:"appliedAt"`
+}
+
+// Status is a migration and when it was applied, if it has been.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+const (
+	collection = "schema-migrations"
+	// A lock older than this is assumed to belong to a crashed process.
+	lockTimeout = 10 * time.Minute
+)
+
+var errLocked = errors.New("migrations are locked by another process")
+
+// Run applies the pending migrations in order and then ensures the query
+// indexes. When another instance is migrating it waits for it to finish.
+func Run(ctx context.Context) error {
+	db := database.DB
+
+	release, err := lock(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range All {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		start := time.Now()
+		utils.Logger.Info("Applying migration", zap.Int("version", m.Version), zap.String("name", m.Name))
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+
+		record := Applied{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}
+		if _, err := db.Collection(collection).InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("recording migration %d: %w", m.Version, err)
+		}
+		utils.Logger.Info("Applied migration", zap.Int("version", m.Version), zap.Duration("took", time.Since(start)))
+	}
+
+	return database.EnsureIndexes(ctx)
+}
+
+// Statuses lists every migration with when it was applied.
+func Statuses(ctx context.Context) ([]Status, error) {
+	applied, err := appliedVersions(ctx, database.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(All))
+	for _, m := range All {
+		status := Status{Migration: m}
+		if record, ok := applied[m.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func appliedVersions(ctx context.Context, db *mongo.Database) (map[int]Applied, error) {
+	cursor, err := db.Collection(collection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Applied
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]Applied, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// lock keeps instances starting together from migrating at the same time,
+// retrying until the other one is done or ctx ends.
+func lock(ctx context.Context, db *mongo.Database) (func(), error) {
+	locks := db.Collection("migration-lock")
+	host, _ := os.Hostname()
+	owner := host + ":" + strconv.Itoa(os.Getpid()) + ":" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	for {
+		now := time.Now()
+		_, err := locks.UpdateOne(ctx,
+			bson.M{"_id": "migrations", "lockedAt": bson.M{"$lt": now.Add(-lockTimeout)}},
+			bson.M{"$set": bson.M{"owner": owner, "lockedAt": now}},
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			break
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+
+		utils.Logger.Info("Waiting for another process to finish migrating")
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", errLocked, ctx.Err())
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if _, err := locks.DeleteOne(ctx, bson.M{"_id": "migrations", "owner": owner}); err != nil {
+			utils.Logger.Warn("Failed to release migration lock", zap.Error(err))
+		}
+	}, nil
+}
@@ -0,0 +1,317 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/services"
+)
+
+// All lists the migrations in the order they run. Append new ones; never
+// renumber or edit one that has shipped.
+var All = []Migration{
+	{Version: 1, Name: "unique user emails", Up: uniqueUserEmails},
+	{Version: 2, Name: "unique team memberships", Up: uniqueTeamMemberships},
+	{Version: 3, Name: "invite tokens and expiry", Up: inviteExpiry},
+	{Version: 4, Name: "team project IDs as ObjectIDs", Up: teamProjectIDs},
+	{Version: 5, Name: "task status casing", Up: taskStatusCasing},
+	{Version: 6, Name: "task positions", Up: taskPositions},
+	{Version: 7, Name: "unique recurring task occurrences", Up: uniqueOccurrences},
+	{Version: 8, Name: "task priority ranks", Up: taskPriorityRanks},
+	{Version: 9, Name: "verified emails of OIDC users", Up: oidcVerifiedEmails},
+}
+
+// Users can't be merged automatically, so duplicates stop the migration
+// until someone resolves them.
+func uniqueUserEmails(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection("users")
+
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$email", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var duplicates []struct {
+		Email string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		emails := make([]string, 0, len(duplicates))
+		for _, d := range duplicates {
+			emails = append(emails, "'"+d.Email+"'")
+		}
+		return errors.New("several users share the emails " + strings.Join(emails, ", ") + "; merge or rename them and run again")
+	}
+
+	_, err = users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("users_email_unique").SetUnique(true),
+	})
+	return err
+}
+
+// Duplicate memberships keep the admin one, or else the oldest.
+func uniqueTeamMemberships(ctx context.Context, db *mongo.Database) error {
+	members := db.Collection("team-members")
+
+	cursor, err := members.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "joinedat", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"teamId": "$teamId", "user": "$user"},
+			"members": bson.M{"$push": bson.M{"id": "$_id", "role": "$role"}},
+			"count":   bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var duplicates []struct {
+		Members []struct {
+			ID   primitive.ObjectID `bson:"id"`
+			Role string             `bson:"role"`
+		} `bson:"members"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+
+	for _, d := range duplicates {
+		keep := 0
+		for i, m := range d.Members {
+			if strings.EqualFold(m.Role, "Admin") {
+				keep = i
+				break
+			}
+		}
+
+		var remove []primitive.ObjectID
+		for i, m := range d.Members {
+			if i != keep {
+				remove = append(remove, m.ID)
+			}
+		}
+		if _, err := members.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": remove}}); err != nil {
+			return err
+		}
+	}
+
+	_, err = members.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "teamId", Value: 1}, {Key: "user", Value: 1}},
+		Options: options.Index().SetName("team_members_unique").SetUnique(true),
+	})
+	return err
+}
+
+// Invites sent before expiry existed get the usual lifetime from when they
+// were sent. Pending invites are deleted once expired; answered ones stay.
+func inviteExpiry(ctx context.Context, db *mongo.Database) error {
+	invites := db.Collection("invites")
+
+	_, err := invites.UpdateMany(ctx,
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"expiresAt": bson.M{"$add": bson.A{"$createdAt", models.InviteTTL.Milliseconds()}},
+		}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = invites.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token", Value: 1}},
+			Options: options.Index().SetName("invites_token_unique").SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetName("invites_expiry").SetExpireAfterSeconds(0).
+				SetPartialFilterExpression(bson.M{"status": "pending"}),
+		},
+	})
+	return err
+}
+
+// Team.Projects used to hold hex strings. IDs that don't parse point at
+// nothing and are dropped.
+func teamProjectIDs(ctx context.Context, db *mongo.Database) error {
+	converted := bson.M{"$map": bson.M{
+		"input": "$projects",
+		"as":    "p",
+		"in":    bson.M{"$convert": bson.M{"input": "$$p", "to": "objectId", "onError": nil, "onNull": nil}},
+	}}
+
+	_, err := db.Collection("teams").UpdateMany(ctx,
+		bson.M{"projects": bson.M{"$elemMatch": bson.M{"$type": "string"}}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"projects": bson.M{"$filter": bson.M{"input": converted, "as": "p", "cond": bson.M{"$ne": bson.A{"$$p", nil}}}},
+		}}}},
+	)
+	return err
+}
+
+// Statuses that differ from a board column only in casing ("Done",
+// "inprogress") are rewritten to the column's spelling.
+func taskStatusCasing(ctx context.Context, db *mongo.Database) error {
+	cursor, err := db.Collection("projects").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"workflow": 1}))
+	if err != nil {
+		return err
+	}
+
+	var projects []models.Project
+	if err := cursor.All(ctx, &projects); err != nil {
+		return err
+	}
+
+	tasks := db.Collection("tasks")
+	for _, project := range projects {
+		columns := project.Workflow
+		if len(columns) == 0 {
+			columns = models.TaskStatuses
+		}
+
+		for _, column := range columns {
+			_, err := tasks.UpdateMany(ctx,
+				bson.M{"projectId": project.ID, "status": bson.M{
+					"$regex":   "^" + regexp.QuoteMeta(column) + "$",
+					"$options": "i",
+					"$ne":      column,
+				}},
+				bson.M{"$set": bson.M{"status": column}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Tasks created before board ordering go to the bottom of their column,
+// oldest first.
+func taskPositions(ctx context.Context, db *mongo.Database) error {
+	tasks := db.Collection("tasks")
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "projectId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"projectId": 1, "status": 1})
+	cursor, err := tasks.Find(ctx, bson.M{
+		"projectId": bson.M{"$exists": true},
+		"$or":       bson.A{bson.M{"position": bson.M{"$exists": false}}, bson.M{"position": ""}},
+	}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var task models.Task
+		if err := cursor.Decode(&task); err != nil {
+			return err
+		}
+
+		position, err := services.NextPosition(ctx, task.ProjectId, task.Status)
+		if err != nil {
+			return err
+		}
+		if _, err := tasks.UpdateOne(ctx, bson.M{"_id": task.ID}, bson.M{"$set": bson.M{"position": position}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Duplicate instances of a recurring task keep the oldest. The index is what
+// stops two schedulers both inserting the same occurrence.
+func uniqueOccurrences(ctx context.Context, db *mongo.Database) error {
+	tasks := db.Collection("tasks")
+
+	cursor, err := tasks.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"recurrenceOf": bson.M{"$exists": true}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"recurrenceOf": "$recurrenceOf", "occurrence": "$occurrence"},
+			"tasks": bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var duplicates []struct {
+		Tasks []primitive.ObjectID `bson:"tasks"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+
+	for _, d := range duplicates {
+		remove := d.Tasks[1:]
+		if _, err := tasks.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": remove}}); err != nil {
+			return err
+		}
+		_, err := db.Collection("projects").UpdateMany(ctx,
+			bson.M{"tasks": bson.M{"$in": remove}},
+			bson.M{"$pull": bson.M{"tasks": bson.M{"$in": remove}}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tasks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "recurrenceOf", Value: 1}, {Key: "occurrence", Value: 1}},
+		Options: options.Index().SetName("tasks_occurrence_unique").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"recurrenceOf": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// Priorities are strings, which sort alphabetically; the rank sorts them by
+// importance. Tasks without a priority rank 0.
+func taskPriorityRanks(ctx context.Context, db *mongo.Database) error {
+	branches := bson.A{}
+	for priority, rank := range models.TaskPriorities {
+		branches = append(branches, bson.M{"case": bson.M{"$eq": bson.A{"$priority", priority}}, "then": rank})
+	}
+
+	_, err := db.Collection("tasks").UpdateMany(ctx,
+		bson.M{"priorityRank": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"priorityRank": bson.M{"$switch": bson.M{"branches": branches, "default": 0}},
+		}}}},
+	)
+	return err
+}
+
+// Users created by an OIDC sign-in have no password and got their email from
+// the provider, which had verified it. Password accounts stay unverified,
+// including ones that were linked by email before that needed verifying.
+func oidcVerifiedEmails(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("users").UpdateMany(ctx,
+		bson.M{
+			"identities":     bson.M{"$exists": true},
+			"password":       bson.M{"$exists": false},
+			"serviceAccount": bson.M{"$ne": true},
+			"emailVerified":  bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"emailVerified": true}},
+	)
+	return err
+}
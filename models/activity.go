@@ -7,12 +7,20 @@ import (
 )
 
 type ActivityLog struct {
-	ID        primitive.ObjectID `bson:"_id, omitempty"`
-	UserID    string             `bson:"userID"`
-	TeamID    string             `bson:"teamID, omitempty"`
-	ProjectID string             `bson:"projectID, omitempty"`
-	TaskID    string             `bson:"taskID, omitempty"`
-	Action    string             `bson:"action"`
-	Message   string             `bson:"message"`
-	Timestamp time.Time          `bson:"timestamp"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"userID" json:"userID"`
+	TeamID    string             `bson:"teamID,omitempty" json:"teamID,omitempty"`
+	ProjectID string             `bson:"projectID,omitempty" json:"projectID,omitempty"`
+	TaskID    string             `bson:"taskID,omitempty" json:"taskID,omitempty"`
+	Action    string             `bson:"action" json:"action"`
+	Message   string             `bson:"message" json:"message"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+
+	// Set on status changes so stats can measure cycle time.
+	FromStatus string `bson:"fromStatus,omitempty" json:"fromStatus,omitempty"`
+	ToStatus   string `bson:"toStatus,omitempty" json:"toStatus,omitempty"`
+	Completed  bool   `bson:"completed,omitempty" json:"completed,omitempty"`
+
+	// The user an action was done to, such as the assignee of Assign Task.
+	TargetUserID string `bson:"targetUserID,omitempty" json:"targetUserID,omitempty"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InboundHook lets an outside system create tasks in a project by POSTing
+// JSON to a secret URL.
+type InboundHook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TeamId    primitive.ObjectID `bson:"teamId" json:"teamId"`
+	ProjectId primitive.ObjectID `bson:"projectId" json:"projectId"`
+	Name      string             `bson:"name" json:"name"`
+	TokenHash string             `bson:"tokenHash" json:"-"` // sha256 of the URL token; the token itself is never stored
+	Mapping   FieldMapping       `bson:"mapping" json:"mapping"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedBy string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// FieldMapping turns a payload into task fields. Each value is a template
+// where {{path}} is replaced by the value at that dot-separated path in the
+// payload, e.g. "Build failed on {{repository.name}}" or "{{commits.0.id}}".
+type FieldMapping struct {
+	Title          string   `bson:"title" json:"title"`
+	Description    string   `bson:"description,omitempty" json:"description,omitempty"`
+	Labels         []string `bson:"labels,omitempty" json:"labels,omitempty"` // a lone {{path}} to an array adds every element
+	Priority       string   `bson:"priority,omitempty" json:"priority,omitempty"`
+	IdempotencyKey string   `bson:"idempotencyKey,omitempty" json:"idempotencyKey,omitempty"` // used when no Idempotency-Key header is sent
+}
+
+// InboundDelivery records an idempotency key an inbound hook has already
+// turned into a task, so repeats return that task instead of a new one.
+type InboundDelivery struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	HookId    primitive.ObjectID  `bson:"hookId" json:"hookId"`
+	Key       string              `bson:"key" json:"key"`
+	TaskId    *primitive.ObjectID `bson:"taskId,omitempty" json:"taskId,omitempty"`
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+}
@@ -14,4 +14,8 @@ type Invite struct {
 	Status    string             `bson:"status" json:"status"` // pending, accepted, declined
 	SentBy    string             `bson:"sentBy" json:"sentBy"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"` // pending invites are deleted after this
 }
+
+// InviteTTL is how long an invite can be accepted for.
+const InviteTTL = 7 * 24 * time.Hour
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"userId" json:"userId"`
+	Type      string             `bson:"type" json:"type"` // event type, e.g. task.assigned
+	Title     string             `bson:"title" json:"title"`
+	Message   string             `bson:"message" json:"message"`
+	ActorID   string             `bson:"actorId,omitempty" json:"actorId,omitempty"`
+	TeamID    string             `bson:"teamId,omitempty" json:"teamId,omitempty"`
+	ProjectID string             `bson:"projectId,omitempty" json:"projectId,omitempty"`
+	TaskID    string             `bson:"taskId,omitempty" json:"taskId,omitempty"`
+	Read      bool               `bson:"read" json:"read"`
+	ReadAt    *time.Time         `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Notification channels a user can pick per event type.
+const (
+	NotifyInApp = "inapp"
+	NotifyEmail = "email"
+	NotifyBoth  = "both"
+	NotifyNone  = "none"
+)
+
+var NotificationChannels = map[string]bool{NotifyInApp: true, NotifyEmail: true, NotifyBoth: true, NotifyNone: true}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity links a user to their account at an OIDC provider.
+type Identity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	LinkedAt time.Time `bson:"linkedAt" json:"linkedAt"`
+}
+
+// OIDCLogin is a sign-in in progress, kept between the redirect to the
+// provider and the callback. Each one can be completed once.
+type OIDCLogin struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	State     string             `bson:"state"`
+	Provider  string             `bson:"provider"`
+	Nonce     string             `bson:"nonce"`
+	Verifier  string             `bson:"verifier"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	// UserID is set when a signed-in user is linking the provider to their
+	// account instead of signing in.
+	UserID string `bson:"userId,omitempty"`
+}
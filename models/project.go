@@ -12,5 +12,7 @@ type Project struct{
 	TeamId primitive.ObjectID `bson:"teamId" json:"teamId"`
 	CreatedBy string `bson:"createdBy" json:"createdBy"`
 	CreatedAt time.Time `bson:"createdAt" json:"createdat"`
+	StartDate *time.Time `bson:"startDate,omitempty" json:"startDate,omitempty"`
+	Workflow []string `bson:"workflow,omitempty" json:"workflow,omitempty"`//board columns, the default statuses when empty
 	Tasks []string `bson:"tasks" json:"tasks"`
 }
\ No newline at end of file
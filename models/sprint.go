@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Sprint struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProjectId primitive.ObjectID `bson:"projectId" json:"projectId"`
+	TeamId    primitive.ObjectID `bson:"teamId" json:"teamId"`
+	Name      string             `bson:"name" json:"name"`
+	Goal      string             `bson:"goal" json:"goal"`
+	StartDate time.Time          `bson:"startDate" json:"startDate"`
+	EndDate   time.Time          `bson:"endDate" json:"endDate"`
+	State     string             `bson:"state" json:"state"` // planned, active, closed
+	CreatedBy string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ClosedAt  *time.Time         `bson:"closedAt,omitempty" json:"closedAt,omitempty"`
+}
@@ -5,14 +5,49 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// TaskStatuses lists the board columns in display order.
+var TaskStatuses = []string{"pending", "inProgress", "done"}
+
+// TaskPriorities maps each priority to its rank, so tasks can sort by
+// importance rather than alphabetically.
+var TaskPriorities = map[string]int{"low": 1, "medium": 2, "high": 3, "urgent": 4}
+
 type Task struct{
 	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Title string `bson:"title" json:"title"`
 	Description string `bson:"description" json:"descroption"`
+	Checklist []ChecklistItem `bson:"checklist,omitempty" json:"checklist,omitempty"`
+	Labels []string `bson:"labels,omitempty" json:"labels,omitempty"`
 	Status string `bson:"status" json:"status"`//pending, inProgress,done
+	Position string `bson:"position" json:"position"`//lexicographic rank within the status column
+	Priority string `bson:"priority,omitempty" json:"priority,omitempty"`//low, medium, high, urgent
+	PriorityRank int `bson:"priorityRank" json:"-"`//TaskPriorities[Priority], 0 without a priority
 	AssignedTo primitive.ObjectID `bson:"assigned" json:"assigned"`//id of the team member the task is assinged to
 	TeamId primitive.ObjectID `bson:"teamid" json:"teamid"`
 	ProjectId primitive.ObjectID `bson:"projectId,omitempty" json:"projectid"`
+	SprintId *primitive.ObjectID `bson:"sprintId,omitempty" json:"sprintId,omitempty"`//nil while the task sits in the backlog
+	DueDate *time.Time `bson:"dueDate,omitempty" json:"dueDate,omitempty"`
+	Recurrence *Recurrence `bson:"recurrence,omitempty" json:"recurrence,omitempty"`
+	NextRunAt *time.Time `bson:"nextRunAt,omitempty" json:"nextRunAt,omitempty"`//when the scheduler creates the next instance
+	RecurrenceOf *primitive.ObjectID `bson:"recurrenceOf,omitempty" json:"recurrenceOf,omitempty"`//recurring task this instance was created from
+	Occurrence *time.Time `bson:"occurrence,omitempty" json:"occurrence,omitempty"`
+	CompletedAt *time.Time `bson:"completedAt,omitempty" json:"completedAt,omitempty"`//set while the task sits in the project's done column
 	CreatedAt time.Time `bson:"createdAt" json:"createdat"`
 	CreatedBy string`bson:"createdBy" json:"createdBy"`
+}
+
+type ChecklistItem struct {
+	Text string `bson:"text" json:"text"`
+	Done bool   `bson:"done" json:"done"`
+}
+
+// Recurrence describes when a recurring task repeats. Start anchors the
+// time of day, the weeks counted by Interval and the default day of month.
+type Recurrence struct {
+	Frequency string     `bson:"frequency" json:"frequency"` // daily, weekly, monthly
+	Interval  int        `bson:"interval" json:"interval"`
+	Weekdays  []int      `bson:"weekdays,omitempty" json:"weekdays,omitempty"` // 0 = Sunday
+	MonthDay  int        `bson:"monthDay,omitempty" json:"monthDay,omitempty"`
+	Start     time.Time  `bson:"start" json:"start"`
+	Until     *time.Time `bson:"until,omitempty" json:"until,omitempty"`
 }
\ No newline at end of file
@@ -13,5 +13,7 @@ type Team struct{
 	Members []string `bson:"members" json:"members"`
 	CreatedBy string  `bson:"createdby" json:"createdby"`
 	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
-	Projects []string `bson:"projects" json:"projects"`
+	Projects []primitive.ObjectID `bson:"projects" json:"projects"`
+	// Members must have TOTP enabled to log in.
+	Require2FA bool `bson:"require2FA,omitempty" json:"require2FA"`
 }
\ No newline at end of file
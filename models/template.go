@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProjectTemplate is a reusable copy of a project's workflow and task list.
+type ProjectTemplate struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TeamId      primitive.ObjectID `bson:"teamId" json:"teamId"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Workflow    []string           `bson:"workflow,omitempty" json:"workflow,omitempty"`
+	Tasks       []TaskTemplate     `bson:"tasks" json:"tasks"`
+	CreatedBy   string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+type TaskTemplate struct {
+	Title       string          `bson:"title" json:"title"`
+	Description string          `bson:"description" json:"description"`
+	Status      string          `bson:"status" json:"status"`
+	Checklist   []ChecklistItem `bson:"checklist,omitempty" json:"checklist,omitempty"`
+	DueOffset   *int            `bson:"dueOffset,omitempty" json:"dueOffset,omitempty"` // days after the project's start date
+}
@@ -13,4 +13,25 @@ type User struct {
 	Password  string               `bson:"password,omitempty" json:"password,omitempty"`
 	Teams     []primitive.ObjectID `bson:"teams" json:"teams"`
 	CreatedAt time.Time            `bson:"createdAt" json:"createdAt"`
+	// Service accounts are users owned by a team; they have no password and
+	// only authenticate with API tokens.
+	ServiceAccount bool                `bson:"serviceAccount,omitempty" json:"serviceAccount,omitempty"`
+	TeamId         *primitive.ObjectID `bson:"teamId,omitempty" json:"teamId,omitempty"`
+	// Accounts at OIDC providers the user can sign in with.
+	Identities []Identity `bson:"identities,omitempty" json:"identities,omitempty"`
+	// Set when a provider vouched for the email; registering or changing the
+	// email doesn't verify it. Only verified accounts are linked by email.
+	EmailVerified bool `bson:"emailVerified,omitempty" json:"emailVerified,omitempty"`
+	// TOTP two-factor authentication. The pending secret is set between
+	// enrolling and confirming the first code; recovery codes are sha256 hashes.
+	TOTPEnabled       bool     `bson:"totpEnabled,omitempty" json:"totpEnabled"`
+	TOTPSecret        string   `bson:"totpSecret,omitempty" json:"-"`
+	TOTPPendingSecret string   `bson:"totpPendingSecret,omitempty" json:"-"`
+	TOTPLastCounter   int64    `bson:"totpLastCounter,omitempty" json:"-"`
+	RecoveryCodes     []string `bson:"recoveryCodes,omitempty" json:"-"`
+	// Channel per event type (see NotificationChannels); unset types notify in-app.
+	NotificationPrefs map[string]string `bson:"notificationPrefs,omitempty" json:"notificationPrefs,omitempty"`
+	Digest            string            `bson:"digest,omitempty" json:"digest,omitempty"` // daily, weekly; empty when opted out
+	// End of the window the last digest covered; the next one starts here.
+	DigestSentAt *time.Time `bson:"digestSentAt,omitempty" json:"digestSentAt,omitempty"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TeamId    primitive.ObjectID `bson:"teamId" json:"teamId"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"` // HMAC key, only shown once on creation
+	Events    []string           `bson:"events" json:"events"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedBy string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// WebhookDelivery is one attempt to hand an event to a webhook. Pending
+// deliveries are the retry queue; the rest are the delivery log.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	WebhookId      primitive.ObjectID  `bson:"webhookId" json:"webhookId"`
+	TeamId         primitive.ObjectID  `bson:"teamId" json:"teamId"`
+	Event          string              `bson:"event" json:"event"`
+	Payload        string              `bson:"payload" json:"payload"`
+	Status         string              `bson:"status" json:"status"` // pending, delivered, failed
+	Attempts       int                 `bson:"attempts" json:"attempts"`
+	NextAttemptAt  *time.Time          `bson:"nextAttemptAt,omitempty" json:"nextAttemptAt,omitempty"`
+	LastStatusCode int                 `bson:"lastStatusCode,omitempty" json:"lastStatusCode,omitempty"`
+	LastError      string              `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	RedeliveryOf   *primitive.ObjectID `bson:"redeliveryOf,omitempty" json:"redeliveryOf,omitempty"`
+	CreatedAt      time.Time           `bson:"createdAt" json:"createdAt"`
+	DeliveredAt    *time.Time          `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+}
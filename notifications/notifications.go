@@ -0,0 +1,200 @@
+// Package notifications turns domain events into in-app notifications and
+// emails, according to each recipient's preferences.
+package notifications
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// Types lists the event types users can set notification preferences for.
+var Types = []string{events.TaskAssigned, events.TaskStatusChanged, events.MemberInvited}
+
+// Register subscribes the notifier to the events it handles.
+func Register() {
+	for _, eventType := range Types {
+		events.Subscribe(eventType, notify)
+	}
+	events.Subscribe(events.AccountLocked, notifyLocked)
+}
+
+// notifyLocked warns the owner of a locked account in the app and by email,
+// whatever their preferences, since it may mean someone is guessing their
+// password.
+func notifyLocked(ctx context.Context, event events.Event) {
+	userID, _ := event.Data["userId"].(string)
+	user, err := findUser(ctx, userID)
+	if err != nil {
+		utils.Logger.Warn("Failed to find locked account", zap.String("userID", userID), zap.Error(err))
+		return
+	}
+
+	minutes, _ := event.Data["minutes"].(int)
+	title := "Your account was locked"
+	message := "Your account was locked for " + strconv.Itoa(minutes) + " minutes after repeated failed sign-in attempts. " +
+		"If this wasn't you, change your password once you can sign in again, or ask a team admin to unlock it."
+
+	notification := models.Notification{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Type:      event.Type,
+		Title:     title,
+		Message:   message,
+		CreatedAt: event.OccurredAt,
+	}
+	if _, err := database.DB.Collection("notifications").InsertOne(ctx, notification); err != nil {
+		utils.Logger.Warn("Failed to store notification", zap.String("userID", userID), zap.Error(err))
+	}
+
+	if err := utils.SendEmail(user.Email, title, message); err != nil {
+		utils.Logger.Warn("Failed to send lockout email", zap.String("userID", userID), zap.Error(err))
+	}
+}
+
+func notify(ctx context.Context, event events.Event) {
+	recipients, err := recipientsFor(ctx, event)
+	if err != nil {
+		utils.Logger.Warn("Failed to resolve notification recipients", zap.String("event", event.Type), zap.Error(err))
+		return
+	}
+
+	actorName := ""
+	if actor, err := findUser(ctx, event.ActorID); err == nil {
+		actorName = actor.FullName
+	}
+	if actorName == "" {
+		actorName = "Someone"
+	}
+
+	title, message := describe(event, actorName)
+
+	for _, recipientID := range recipients {
+		// Nobody needs telling about their own actions.
+		if recipientID == event.ActorID {
+			continue
+		}
+
+		user, err := findUser(ctx, recipientID)
+		if err != nil {
+			utils.Logger.Warn("Failed to find notification recipient", zap.String("userID", recipientID), zap.Error(err))
+			continue
+		}
+
+		channel := Channel(user, event.Type)
+
+		if channel == models.NotifyInApp || channel == models.NotifyBoth {
+			notification := models.Notification{
+				ID:        primitive.NewObjectID(),
+				UserID:    recipientID,
+				Type:      event.Type,
+				Title:     title,
+				Message:   message,
+				ActorID:   event.ActorID,
+				TeamID:    event.TeamID,
+				ProjectID: event.ProjectID,
+				TaskID:    event.TaskID,
+				CreatedAt: event.OccurredAt,
+			}
+			if _, err := database.DB.Collection("notifications").InsertOne(ctx, notification); err != nil {
+				utils.Logger.Warn("Failed to store notification", zap.String("userID", recipientID), zap.Error(err))
+			}
+		}
+
+		// InviteMember already mails the invite link itself.
+		if (channel == models.NotifyEmail || channel == models.NotifyBoth) && event.Type != events.MemberInvited {
+			if err := utils.SendEmail(user.Email, title, message); err != nil {
+				utils.Logger.Warn("Failed to send notification email", zap.String("userID", recipientID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// Channel returns how user wants to hear about eventType.
+func Channel(user models.User, eventType string) string {
+	if channel, ok := user.NotificationPrefs[PrefKey(eventType)]; ok && models.NotificationChannels[channel] {
+		return channel
+	}
+	return models.NotifyInApp
+}
+
+// PrefKey is the key eventType is stored under in User.NotificationPrefs.
+// Event types contain dots, which Mongo would read as a nested path.
+func PrefKey(eventType string) string {
+	return strings.ReplaceAll(eventType, ".", "_")
+}
+
+func recipientsFor(ctx context.Context, event events.Event) ([]string, error) {
+	switch event.Type {
+	case events.TaskAssigned:
+		assignee, _ := event.Data["assigneeId"].(string)
+		return []string{assignee}, nil
+
+	case events.TaskStatusChanged:
+		taskID, err := primitive.ObjectIDFromHex(event.TaskID)
+		if err != nil {
+			return nil, err
+		}
+
+		var task models.Task
+		if err := database.DB.Collection("tasks").FindOne(ctx, bson.M{"_id": taskID}).Decode(&task); err != nil {
+			return nil, err
+		}
+
+		recipients := []string{}
+		if !task.AssignedTo.IsZero() {
+			recipients = append(recipients, task.AssignedTo.Hex())
+		}
+		if task.CreatedBy != "" && task.CreatedBy != task.AssignedTo.Hex() {
+			recipients = append(recipients, task.CreatedBy)
+		}
+		return recipients, nil
+
+	case events.MemberInvited:
+		invitee, _ := event.Data["userId"].(string)
+		return []string{invitee}, nil
+	}
+
+	return nil, nil
+}
+
+func describe(event events.Event, actorName string) (string, string) {
+	title, _ := event.Data["title"].(string)
+
+	switch event.Type {
+	case events.TaskAssigned:
+		return "Task assigned to you", actorName + " assigned you '" + title + "'"
+
+	case events.TaskStatusChanged:
+		from, _ := event.Data["from"].(string)
+		to, _ := event.Data["to"].(string)
+		return "Task status changed", actorName + " moved '" + title + "' from " + from + " to " + to
+
+	case events.MemberInvited:
+		teamName, _ := event.Data["teamName"].(string)
+		return "Team invitation", actorName + " invited you to join " + teamName
+	}
+
+	return event.Type, actorName + " triggered " + event.Type
+}
+
+func findUser(ctx context.Context, userID string) (models.User, error) {
+	var user models.User
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return user, err
+	}
+
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	return user, err
+}
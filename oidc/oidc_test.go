@@ -0,0 +1,304 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is a minimal in-process OpenID provider: discovery, a JWKS
+// with one RSA key, and a token endpoint that enforces PKCE. Authorization
+// is done by calling authorize directly with the query AuthURL produced.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu        sync.Mutex
+	codes     map[string]authorization
+	jwksFetch int
+
+	// Overrides for the next ID token.
+	audience string
+	nonce    string
+	signKid  string
+	email    string
+	verified bool
+}
+
+type authorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockProvider{t: t, key: key, kid: "key-1", codes: map[string]authorization{}, email: "ada@example.com", verified: true}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		m.jwksFetch++
+		m.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": m.kid,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.token)
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockProvider) provider() *Provider {
+	return &Provider{
+		Name:        "mock",
+		Issuer:      m.server.URL,
+		ClientID:    "collab",
+		RedirectURL: "https://collab.example.com/auth/oidc/mock/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+}
+
+// authorize plays the user signing in: it checks the authorization request
+// and returns the code and the state to send back to the callback.
+func (m *mockProvider) authorize(authURL string) (code, state string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	code, _ = RandomString()
+	m.mu.Lock()
+	m.codes[code] = authorization{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	m.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	fail := func(reason string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": reason})
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		fail("wrong grant type")
+		return
+	case !ok:
+		fail("unknown or used code")
+		return
+	case r.PostForm.Get("client_id") != auth.clientID || r.PostForm.Get("redirect_uri") != auth.redirectURI:
+		fail("client or redirect mismatch")
+		return
+	case CodeChallenge(r.PostForm.Get("code_verifier")) != auth.challenge:
+		fail("PKCE verification failed")
+		return
+	}
+
+	audience, nonce, kid := auth.clientID, auth.nonce, m.kid
+	if m.audience != "" {
+		audience = m.audience
+	}
+	if m.nonce != "" {
+		nonce = m.nonce
+	}
+	if m.signKid != "" {
+		kid = m.signKid
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "subject-1",
+		"aud":            audience,
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          m.email,
+		"email_verified": m.verified,
+		"name":           "Ada Lovelace",
+	}, kid)})
+}
+
+func (m *mockProvider) sign(claims jwt.MapClaims, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed
+}
+
+// login runs the whole flow the way the handlers do and returns the claims.
+func login(t *testing.T, m *mockProvider, p *Provider) (*Claims, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	state, _ := RandomString()
+	nonce, _ := RandomString()
+	verifier, _ := RandomString()
+
+	authURL, err := p.AuthURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, returned := m.authorize(authURL)
+	if returned != state {
+		t.Fatalf("state = %q, want %q", returned, state)
+	}
+	return p.Exchange(ctx, code, verifier, nonce)
+}
+
+func TestLoginRoundTrip(t *testing.T) {
+	m := newMockProvider(t)
+
+	claims, err := login(t, m, m.provider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "ada@example.com" || !claims.EmailVerified || claims.Name != "Ada Lovelace" {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestExchangeSendsVerifierForChallenge(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider()
+	ctx := context.Background()
+
+	authURL, err := p.AuthURL(ctx, "state", "nonce", "the-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := m.authorize(authURL)
+
+	if _, err := p.Exchange(ctx, code, "another-verifier", "nonce"); err == nil || !strings.Contains(err.Error(), "PKCE") {
+		t.Fatalf("Exchange with the wrong verifier = %v, want a PKCE error", err)
+	}
+}
+
+func TestExchangeRejectsUsedCode(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider()
+	ctx := context.Background()
+
+	authURL, _ := p.AuthURL(ctx, "state", "nonce", "verifier")
+	code, _ := m.authorize(authURL)
+	if _, err := p.Exchange(ctx, code, "verifier", "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(ctx, code, "verifier", "nonce"); err == nil {
+		t.Fatal("a code was redeemed twice")
+	}
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	m.nonce = "replayed-nonce"
+
+	if _, err := login(t, m, m.provider()); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("login = %v, want a nonce error", err)
+	}
+}
+
+func TestExchangeRejectsWrongAudience(t *testing.T) {
+	m := newMockProvider(t)
+	m.audience = "some-other-client"
+
+	if _, err := login(t, m, m.provider()); err == nil || !strings.Contains(err.Error(), "aud") {
+		t.Fatalf("login = %v, want an audience error", err)
+	}
+}
+
+func TestExchangeRejectsUnknownKey(t *testing.T) {
+	m := newMockProvider(t)
+	m.signKid = "not-published"
+
+	if _, err := login(t, m, m.provider()); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("login = %v, want an unknown key error", err)
+	}
+}
+
+func TestVerifyRefetchesRotatedKeys(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider()
+
+	if _, err := login(t, m, p); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mu.Lock()
+	m.kid = "key-2"
+	m.mu.Unlock()
+	if _, err := login(t, m, p); err != nil {
+		t.Fatalf("login after rotation = %v", err)
+	}
+	if m.jwksFetch != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", m.jwksFetch)
+	}
+}
+
+func TestVerifyRejectsOtherIssuer(t *testing.T) {
+	m := newMockProvider(t)
+	p := m.provider()
+
+	token := m.sign(jwt.MapClaims{
+		"iss":   "https://evil.example.com",
+		"sub":   "subject-1",
+		"aud":   p.ClientID,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": "n",
+	}, m.kid)
+	if _, err := p.Verify(context.Background(), token, "n"); err == nil {
+		t.Fatal("a token from another issuer was accepted")
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+)
+
+// LoginPolicy is how failed logins against one key are slowed down. After
+// FreeFailures, each failure doubles the wait before the next attempt, from
+// one second up to MaxDelay; at LockAfter failures the key is locked for
+// LockFor. Failures are forgotten after ResetAfter without another one.
+type LoginPolicy struct {
+	FreeFailures int
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockFor      time.Duration
+	ResetAfter   time.Duration
+}
+
+var (
+	// AccountLoginPolicy protects one account from password guessing.
+	AccountLoginPolicy = LoginPolicy{FreeFailures: 3, MaxDelay: 30 * time.Second, LockAfter: 10, LockFor: 15 * time.Minute, ResetAfter: time.Hour}
+
+	// IPLoginPolicy is looser, since an office can share one address, but
+	// stops a single client spraying passwords across many accounts.
+	IPLoginPolicy = LoginPolicy{FreeFailures: 10, MaxDelay: 30 * time.Second, LockAfter: 50, LockFor: 15 * time.Minute, ResetAfter: time.Hour}
+)
+
+// AccountLoginKey and IPLoginKey are the login-attempts keys for an account
+// and a client. Accounts are keyed by the email as typed, whether or not it
+// exists, so lockouts don't reveal which emails are registered.
+func AccountLoginKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// delay is the wait the policy imposes after failures failed attempts.
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if failures <= p.FreeFailures {
+		return 0
+	}
+	delay := time.Second
+	for i := p.FreeFailures + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// wait is how long the policy makes the next attempt against attempt's key
+// wait, or zero if it may go ahead now.
+func (p LoginPolicy) wait(attempt models.LoginAttempt, now time.Time) time.Duration {
+	until := attempt.LastFailureAt.Add(p.delay(attempt.Failures))
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(until) {
+		until = *attempt.LockedUntil
+	}
+	if until.After(now) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// LoginClaim is an attempt claimed with ClaimLoginAttempt. The zero value is
+// an attempt that wasn't counted.
+type LoginClaim struct {
+	Key string
+	// Locks is true when this attempt locked the key, should it fail.
+	Locks bool
+}
+
+// ClaimLoginAttempt counts an attempt against key before the credential is
+// checked, or returns how long the caller must wait instead. Every attempt is
+// counted as a failure up front, so parallel guesses can't all get in before
+// the first of them fails; a successful one is taken back with
+// ReleaseLoginAttempt or ClearLoginFailures.
+func ClaimLoginAttempt(ctx context.Context, key string, policy LoginPolicy, now time.Time) (LoginClaim, time.Duration, error) {
+	collection := database.DB.Collection("login-attempts")
+
+	// The update only applies while failures is still the value that was
+	// read, so of several attempts racing for one slot only one gets it; the
+	// others read again and see the delay it started.
+	for try := 0; try < 3; try++ {
+		var attempt models.LoginAttempt
+		err := collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempt)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return LoginClaim{}, 0, err
+		}
+
+		failures := attempt.Failures
+		if now.Sub(attempt.LastFailureAt) >= policy.ResetAfter {
+			failures = 0
+		} else if wait := policy.wait(attempt, now); wait > 0 {
+			return LoginClaim{}, wait, nil
+		}
+		failures++
+
+		claim := LoginClaim{Key: key, Locks: failures >= policy.LockAfter}
+		set := bson.M{"failures": failures, "lastFailureAt": now}
+		update := bson.M{"$set": set, "$unset": bson.M{"lockedUntil": ""}}
+		// Once past LockAfter, every attempt after a lock runs out locks again.
+		if claim.Locks {
+			set["lockedUntil"] = now.Add(policy.LockFor)
+			update = bson.M{"$set": set}
+		}
+
+		// A missing document is upserted; losing that race is a duplicate key.
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": key, "failures": attempt.Failures},
+			update,
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			return claim, 0, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return LoginClaim{}, 0, err
+		}
+	}
+
+	// Lost every race to attempts that are now being checked.
+	return LoginClaim{}, time.Second, nil
+}
+
+// ReleaseLoginAttempt takes back a claimed attempt that succeeded.
+func ReleaseLoginAttempt(ctx context.Context, claim LoginClaim) error {
+	if claim.Key == "" {
+		return nil
+	}
+
+	update := bson.M{"$inc": bson.M{"failures": -1}}
+	if claim.Locks {
+		update["$unset"] = bson.M{"lockedUntil": ""}
+	}
+	_, err := database.DB.Collection("login-attempts").UpdateOne(ctx, bson.M{"_id": claim.Key, "failures": bson.M{"$gt": 0}}, update)
+	return err
+}
+
+// ClearLoginFailures forgets the failures counted against key, after a
+// successful login or when an admin unlocks an account.
+func ClearLoginFailures(ctx context.Context, key string) error {
+	_, err := database.DB.Collection("login-attempts").DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Loboo34/collab-api/models"
+)
+
+var placeholder = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// LookupPath walks a decoded JSON value along a dot-separated path. Numeric
+// segments index into arrays.
+func LookupPath(payload interface{}, path string) (interface{}, bool) {
+	current := payload
+	for _, segment := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil, false
+			}
+			current = value[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// RenderTemplate replaces every {{path}} in tmpl with the payload value at
+// that path. Missing values render as empty strings.
+func RenderTemplate(tmpl string, payload interface{}) string {
+	return placeholder.ReplaceAllStringFunc(tmpl, func(match string) string {
+		path := placeholder.FindStringSubmatch(match)[1]
+		value, ok := LookupPath(payload, path)
+		if !ok || value == nil {
+			return ""
+		}
+		return stringify(value)
+	})
+}
+
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// MapPayload builds task fields from a payload using mapping. The result
+// still has to go through ValidateTask.
+func MapPayload(mapping models.FieldMapping, payload interface{}) models.Task {
+	task := models.Task{
+		Title:       RenderTemplate(mapping.Title, payload),
+		Description: RenderTemplate(mapping.Description, payload),
+		Priority:    RenderTemplate(mapping.Priority, payload),
+	}
+
+	for _, tmpl := range mapping.Labels {
+		// A template that is just one placeholder may point at an array.
+		if match := placeholder.FindStringSubmatch(tmpl); match != nil && match[0] == strings.TrimSpace(tmpl) {
+			if values, ok := LookupPath(payload, match[1]); ok {
+				if list, ok := values.([]interface{}); ok {
+					for _, value := range list {
+						task.Labels = append(task.Labels, stringify(value))
+					}
+					continue
+				}
+			}
+		}
+		task.Labels = append(task.Labels, RenderTemplate(tmpl, payload))
+	}
+
+	return task
+}
+
+// ValidateMapping checks a mapping before it is saved.
+func ValidateMapping(mapping models.FieldMapping) error {
+	if strings.TrimSpace(mapping.Title) == "" {
+		return errors.New("Mapping title is required")
+	}
+	return nil
+}
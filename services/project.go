@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateProject saves a new project and links it to its team.
+func CreateProject(ctx context.Context, project *models.Project) error {
+	if project.ID.IsZero() {
+		project.ID = primitive.NewObjectID()
+	}
+	if project.Tasks == nil {
+		project.Tasks = []string{}
+	}
+
+	_, err := database.DB.Collection("projects").InsertOne(ctx, project)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("teams").UpdateOne(ctx, bson.M{"_id": project.TeamId}, bson.M{"$addToSet": bson.M{"projects": project.ID}})
+	return err
+}
+
+// RemoveProject deletes a project with its tasks and unlinks it from its team.
+func RemoveProject(ctx context.Context, project models.Project) error {
+	if _, err := database.DB.Collection("tasks").DeleteMany(ctx, bson.M{"projectId": project.ID}); err != nil {
+		return err
+	}
+	if _, err := database.DB.Collection("projects").DeleteOne(ctx, bson.M{"_id": project.ID}); err != nil {
+		return err
+	}
+	_, err := database.DB.Collection("teams").UpdateOne(ctx, bson.M{"_id": project.TeamId}, bson.M{"$pull": bson.M{"projects": project.ID}})
+	return err
+}
+
+// Workflow returns the project's board columns, falling back to the default statuses.
+func Workflow(project models.Project) []string {
+	if len(project.Workflow) > 0 {
+		return project.Workflow
+	}
+	return models.TaskStatuses
+}
+
+// ValidStatus reports whether status is one of the project's board columns.
+func ValidStatus(project models.Project, status string) bool {
+	for _, column := range Workflow(project) {
+		if column == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWorkflow checks a custom workflow has named, unique columns.
+func ValidateWorkflow(workflow []string) error {
+	seen := map[string]bool{}
+	for _, column := range workflow {
+		if column == "" {
+			return errors.New("Workflow columns must have a name")
+		}
+		if seen[column] {
+			return errors.New("Workflow column '" + column + "' is listed twice")
+		}
+		seen[column] = true
+	}
+	return nil
+}
+
+// DoneStatus is the last column of the project's workflow, where finished tasks end up.
+func DoneStatus(project models.Project) string {
+	workflow := Workflow(project)
+	return workflow[len(workflow)-1]
+}
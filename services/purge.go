@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+)
+
+// Orphans is what PurgeOrphans found, per collection.
+type Orphans map[string]int64
+
+// PurgeOrphans deletes data left behind by deleted teams and projects, whose
+// handlers only remove the records closest to them. With dryRun nothing is
+// deleted and the counts are what would be.
+//
+// Only records created before the run started are considered: one created
+// since may belong to a team or project that didn't exist when the parents
+// were read.
+func PurgeOrphans(ctx context.Context, dryRun bool) (Orphans, error) {
+	db := database.DB
+	found := Orphans{}
+	cutoff := primitive.NewObjectIDFromTimestamp(time.Now())
+
+	teamIDs, err := distinctIDs(ctx, db.Collection("teams"), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	teamHexes := make(bson.A, 0, len(teamIDs))
+	for _, id := range teamIDs {
+		teamHexes = append(teamHexes, id.(primitive.ObjectID).Hex())
+	}
+
+	// Children of orphaned parents are orphans too, even before a dry run
+	// would have deleted the parents.
+	projectIDs, err := distinctIDs(ctx, db.Collection("projects"), bson.M{"teamId": bson.M{"$in": teamIDs}})
+	if err != nil {
+		return nil, err
+	}
+	hookIDs, err := distinctIDs(ctx, db.Collection("inbound-hooks"), bson.M{"projectId": bson.M{"$in": projectIDs}})
+	if err != nil {
+		return nil, err
+	}
+	accountIDs, err := distinctIDs(ctx, db.Collection("users"), bson.M{"serviceAccount": true, "teamId": bson.M{"$nin": teamIDs}})
+	if err != nil {
+		return nil, err
+	}
+	accountHexes := make(bson.A, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		accountHexes = append(accountHexes, id.(primitive.ObjectID).Hex())
+	}
+
+	orphans := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{"team-members", bson.M{"teamId": bson.M{"$nin": teamIDs}}},
+		{"invites", bson.M{"teamId": bson.M{"$nin": teamIDs}}},
+		{"projects", bson.M{"teamId": bson.M{"$nin": teamIDs}}},
+		{"sprints", bson.M{"projectId": bson.M{"$nin": projectIDs}}},
+		{"tasks", bson.M{"projectId": bson.M{"$exists": true, "$nin": projectIDs}}},
+		{"templates", bson.M{"teamId": bson.M{"$nin": teamIDs}}},
+		{"webhooks", bson.M{"teamId": bson.M{"$nin": teamIDs}}},
+		{"webhook-deliveries", bson.M{"teamId": bson.M{"$nin": teamIDs}}},
+		{"inbound-hooks", bson.M{"projectId": bson.M{"$nin": projectIDs}}},
+		{"inbound-deliveries", bson.M{"hookId": bson.M{"$nin": hookIDs}}},
+		{"messages", bson.M{"temaid": bson.M{"$exists": true, "$nin": teamHexes}}},
+		{"activity-log", bson.M{"teamID": bson.M{"$exists": true, "$nin": teamHexes}}},
+		{"api-tokens", bson.M{"userId": bson.M{"$in": accountHexes}}},
+		{"users", bson.M{"_id": bson.M{"$in": accountIDs, "$lt": cutoff}}},
+	}
+
+	for _, orphan := range orphans {
+		collection := db.Collection(orphan.collection)
+		if _, ok := orphan.filter["_id"]; !ok {
+			orphan.filter["_id"] = bson.M{"$lt": cutoff}
+		}
+
+		var count int64
+		if dryRun {
+			count, err = collection.CountDocuments(ctx, orphan.filter)
+		} else {
+			var result *mongo.DeleteResult
+			result, err = collection.DeleteMany(ctx, orphan.filter)
+			if result != nil {
+				count = result.DeletedCount
+			}
+		}
+		if err != nil {
+			return found, err
+		}
+		if count > 0 {
+			found[orphan.collection] = count
+		}
+	}
+
+	// Users keep a list of their teams.
+	staleTeam := bson.M{"$nin": teamIDs, "$lt": cutoff}
+	staleTeams := bson.M{"teams": bson.M{"$elemMatch": staleTeam}}
+	var stale int64
+	if dryRun {
+		stale, err = db.Collection("users").CountDocuments(ctx, staleTeams)
+	} else {
+		var result *mongo.UpdateResult
+		result, err = db.Collection("users").UpdateMany(ctx, staleTeams, bson.M{"$pull": bson.M{"teams": staleTeam}})
+		if result != nil {
+			stale = result.ModifiedCount
+		}
+	}
+	if err != nil {
+		return found, err
+	}
+	if stale > 0 {
+		found["users.teams"] = stale
+	}
+
+	return found, nil
+}
+
+func distinctIDs(ctx context.Context, collection *mongo.Collection, filter bson.M) (bson.A, error) {
+	ids, err := collection.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	if ids == nil {
+		return bson.A{}, nil
+	}
+	return bson.A(ids), nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// ErrNoRoom is returned when no rank sorts strictly between two ranks, such
+// as "a" and "a0"; the column has to be rebalanced first.
+var ErrNoRoom = errors.New("No rank between the given ranks")
+
+// RankBetween returns a rank that sorts strictly between prev and next.
+// An empty prev means the start of the column and an empty next means the end,
+// so only the moved task ever needs to be rewritten.
+func RankBetween(prev, next string) (string, error) {
+	if !validRank(prev) || !validRank(next) {
+		return "", errors.New("Invalid rank")
+	}
+	if next != "" && prev >= next {
+		return "", errors.New("Previous rank must sort before next rank")
+	}
+
+	base := len(rankDigits)
+	bounded := next != ""
+	var rank []byte
+
+	for i := 0; ; i++ {
+		lo := 0
+		if i < len(prev) {
+			lo = strings.IndexByte(rankDigits, prev[i])
+		}
+
+		hi := base
+		if bounded {
+			// So far the rank equals next, and anything longer sorts after it.
+			if i >= len(next) {
+				return "", ErrNoRoom
+			}
+			hi = strings.IndexByte(rankDigits, next[i])
+		}
+
+		if hi-lo > 1 {
+			rank = append(rank, rankDigits[(lo+hi)/2])
+			return string(rank), nil
+		}
+
+		rank = append(rank, rankDigits[lo])
+		if hi > lo {
+			bounded = false
+		}
+	}
+}
+
+// EvenRanks returns n ascending ranks of equal length spread evenly over the
+// rank space, leaving room on both sides of each.
+func EvenRanks(n int) []string {
+	base := len(rankDigits)
+	width, space := 1, base
+	for space < 2*(n+1) {
+		width++
+		space *= base
+	}
+	step := space / (n + 1)
+
+	ranks := make([]string, n)
+	for k := range ranks {
+		rank := make([]byte, width)
+		value := (k + 1) * step
+		for i := width - 1; i >= 0; i-- {
+			rank[i] = rankDigits[value%base]
+			value /= base
+		}
+		ranks[k] = string(rank)
+	}
+	return ranks
+}
+
+func validRank(rank string) bool {
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(rankDigits, rank[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
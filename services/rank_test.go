@@ -0,0 +1,88 @@
+package services
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		prev, next string
+		want       string
+		err        error
+	}{
+		{"", "", "i", nil},
+		{"i", "", "r", nil},
+		{"", "i", "9", nil},
+		{"a", "c", "b", nil},
+		{"a", "b", "ai", nil},
+		{"a", "a1", "a0i", nil},
+		{"", "01", "00i", nil},
+		{"z", "", "zi", nil},
+		{"", "0", "", ErrNoRoom},
+		{"a", "a0", "", ErrNoRoom},
+		{"a", "a00", "", ErrNoRoom},
+	}
+	for _, tt := range tests {
+		got, err := RankBetween(tt.prev, tt.next)
+		if got != tt.want || err != tt.err {
+			t.Errorf("RankBetween(%q, %q) = %q, %v; want %q, %v", tt.prev, tt.next, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestRankBetweenRejects(t *testing.T) {
+	for _, pair := range [][2]string{{"b", "a"}, {"a", "a"}, {"A", ""}, {"", "a-"}} {
+		if _, err := RankBetween(pair[0], pair[1]); err == nil {
+			t.Errorf("RankBetween(%q, %q) succeeded", pair[0], pair[1])
+		}
+	}
+}
+
+// TestRankBetweenSorts checks random inserts always land strictly between
+// their neighbours, or report that there is no room.
+func TestRankBetweenSorts(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ranks := []string{"i"}
+	for n := 0; n < 2000; n++ {
+		i := r.Intn(len(ranks) + 1)
+		prev, next := "", ""
+		if i > 0 {
+			prev = ranks[i-1]
+		}
+		if i < len(ranks) {
+			next = ranks[i]
+		}
+
+		rank, err := RankBetween(prev, next)
+		if err == ErrNoRoom {
+			continue
+		}
+		if err != nil || rank <= prev || (next != "" && rank >= next) {
+			t.Fatalf("RankBetween(%q, %q) = %q, %v", prev, next, rank, err)
+		}
+		ranks = append(ranks[:i], append([]string{rank}, ranks[i:]...)...)
+	}
+}
+
+func TestEvenRanks(t *testing.T) {
+	for _, n := range []int{1, 2, 17, 35, 36, 1000} {
+		ranks := EvenRanks(n)
+		if len(ranks) != n {
+			t.Fatalf("EvenRanks(%d) returned %d ranks", n, len(ranks))
+		}
+		prev := ""
+		for _, rank := range ranks {
+			if !validRank(rank) || rank <= prev || len(rank) != len(ranks[0]) {
+				t.Fatalf("EvenRanks(%d) = %v", n, ranks)
+			}
+			if _, err := RankBetween(prev, rank); err != nil {
+				t.Errorf("EvenRanks(%d): no room before %q", n, rank)
+			}
+			prev = rank
+		}
+		if _, err := RankBetween(prev, ""); err != nil {
+			t.Errorf("EvenRanks(%d): no room after %q", n, prev)
+		}
+	}
+}
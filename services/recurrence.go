@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Loboo34/collab-api/models"
+)
+
+var rruleWeekdays = map[string]int{"SU": 0, "MO": 1, "TU": 2, "WE": 3, "TH": 4, "FR": 5, "SA": 6}
+
+// ParseRRule parses the subset of RFC 5545 recurrence rules we support:
+// FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, BYDAY, BYMONTHDAY and UNTIL.
+func ParseRRule(rule string, start time.Time) (models.Recurrence, error) {
+	recurrence := models.Recurrence{Interval: 1, Start: start}
+
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return recurrence, errors.New("Invalid rule part: " + part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			recurrence.Frequency = strings.ToLower(value)
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil {
+				return recurrence, errors.New("Invalid INTERVAL")
+			}
+			recurrence.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return recurrence, errors.New("Invalid BYDAY value: " + day)
+				}
+				recurrence.Weekdays = append(recurrence.Weekdays, weekday)
+			}
+		case "BYMONTHDAY":
+			day, err := strconv.Atoi(value)
+			if err != nil {
+				return recurrence, errors.New("Invalid BYMONTHDAY")
+			}
+			recurrence.MonthDay = day
+		case "UNTIL":
+			until, err := parseRRuleTime(value)
+			if err != nil {
+				return recurrence, errors.New("Invalid UNTIL")
+			}
+			recurrence.Until = &until
+		default:
+			return recurrence, errors.New("Unsupported rule part: " + key)
+		}
+	}
+
+	return recurrence, ValidateRecurrence(recurrence)
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	if len(value) == len("20060102") {
+		return time.ParseInLocation("20060102", value, time.UTC)
+	}
+	return time.Parse("20060102T150405Z", value)
+}
+
+func ValidateRecurrence(recurrence models.Recurrence) error {
+	switch recurrence.Frequency {
+	case "daily", "weekly", "monthly":
+	default:
+		return errors.New("Frequency must be daily, weekly or monthly")
+	}
+
+	if recurrence.Interval < 1 {
+		return errors.New("Interval must be at least 1")
+	}
+
+	for _, weekday := range recurrence.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			return errors.New("Weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+
+	if recurrence.MonthDay < 0 || recurrence.MonthDay > 31 {
+		return errors.New("Month day must be between 1 and 31")
+	}
+
+	if recurrence.Start.IsZero() {
+		return errors.New("Recurrence start is required")
+	}
+
+	return nil
+}
+
+// NextOccurrence returns the first occurrence strictly after the given time,
+// and false once the rule has run past its Until date.
+func NextOccurrence(recurrence models.Recurrence, after time.Time) (time.Time, bool) {
+	var next time.Time
+
+	switch recurrence.Frequency {
+	case "daily":
+		next = nextDaily(recurrence, after)
+	case "weekly":
+		next = nextWeekly(recurrence, after)
+	case "monthly":
+		next = nextMonthly(recurrence, after)
+	default:
+		return time.Time{}, false
+	}
+
+	if next.IsZero() || (recurrence.Until != nil && next.After(*recurrence.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func nextDaily(recurrence models.Recurrence, after time.Time) time.Time {
+	start := recurrence.Start
+	if after.Before(start) {
+		return start
+	}
+
+	steps := int(after.Sub(start).Hours()/24) / recurrence.Interval
+	next := start.AddDate(0, 0, steps*recurrence.Interval)
+	for !next.After(after) {
+		next = next.AddDate(0, 0, recurrence.Interval)
+	}
+	return next
+}
+
+func nextWeekly(recurrence models.Recurrence, after time.Time) time.Time {
+	start := recurrence.Start
+	weekdays := recurrence.Weekdays
+	if len(weekdays) == 0 {
+		weekdays = []int{int(start.Weekday())}
+	}
+
+	from := after
+	if from.Before(start) {
+		from = start.Add(-time.Nanosecond)
+	}
+
+	// Walk day by day at the anchor's time of day; the interval counts whole
+	// weeks since the week the rule started in.
+	day := time.Date(from.Year(), from.Month(), from.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	startWeek := startOfWeek(start)
+	for i := 0; i <= 7*(recurrence.Interval+1); i++ {
+		candidate := day.AddDate(0, 0, i)
+		if !candidate.After(from) {
+			continue
+		}
+
+		weeks := int(startOfWeek(candidate).Sub(startWeek).Hours()/24+0.5) / 7
+		if weeks%recurrence.Interval != 0 {
+			continue
+		}
+
+		for _, weekday := range weekdays {
+			if int(candidate.Weekday()) == weekday {
+				return candidate
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func startOfWeek(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+}
+
+func nextMonthly(recurrence models.Recurrence, after time.Time) time.Time {
+	start := recurrence.Start
+	monthDay := recurrence.MonthDay
+	if monthDay == 0 {
+		monthDay = start.Day()
+	}
+
+	for months := 0; ; months += recurrence.Interval {
+		first := time.Date(start.Year(), start.Month()+time.Month(months), 1, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+
+		// Months shorter than the requested day fall back to their last day.
+		day := monthDay
+		if last := first.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+
+		candidate := first.AddDate(0, 0, day-1)
+		if candidate.After(after) && !candidate.Before(start) {
+			return candidate
+		}
+	}
+}
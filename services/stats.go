@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Loboo34/collab-api/database"
+)
+
+// StatsScope selects the tasks and activity a stats request covers. Tasks
+// filters the tasks collection, Activity the activity-log collection, and
+// DoneStatuses holds the done column of every project in scope.
+type StatsScope struct {
+	Tasks        bson.M
+	Activity     bson.M
+	DoneStatuses []string
+
+	Weeks int // throughput window
+
+	// The burndown runs day by day over [BurndownFrom, BurndownTo] for the
+	// tasks matching BurndownTasks, or Tasks when that is nil.
+	BurndownTasks bson.M
+	BurndownFrom  time.Time
+	BurndownTo    time.Time
+}
+
+type StatusCount struct {
+	Status string `bson:"_id" json:"status"`
+	Count  int64  `bson:"count" json:"count"`
+}
+
+type AssigneeCount struct {
+	UserID primitive.ObjectID `bson:"userId" json:"userId"`
+	Name   string             `bson:"name" json:"name"`
+	Total  int64              `bson:"total" json:"total"`
+	Open   int64              `bson:"open" json:"open"`
+}
+
+type WeekCount struct {
+	Week  time.Time `bson:"week" json:"week"`
+	Count int64     `bson:"count" json:"count"`
+}
+
+type BurndownPoint struct {
+	Date      time.Time `bson:"date" json:"date"`
+	Scope     int64     `bson:"scope" json:"scope"`
+	Remaining int64     `bson:"remaining" json:"remaining"`
+}
+
+type TaskStats struct {
+	Total             int64           `json:"total"`
+	Completed         int64           `json:"completed"`
+	Overdue           int64           `json:"overdue"`
+	ByStatus          []StatusCount   `json:"byStatus"`
+	ByAssignee        []AssigneeCount `json:"byAssignee"`
+	Throughput        []WeekCount     `json:"throughput"`
+	AvgCycleTimeHours *float64        `json:"avgCycleTimeHours"`
+	CycleTimeSamples  int64           `json:"cycleTimeSamples"`
+	Burndown          []BurndownPoint `json:"burndown"`
+}
+
+// Stats computes the dashboard numbers for a scope.
+func Stats(ctx context.Context, scope StatsScope, now time.Time) (TaskStats, error) {
+	stats := TaskStats{}
+
+	if err := taskCounts(ctx, scope, now, &stats); err != nil {
+		return stats, err
+	}
+	if err := cycleTime(ctx, scope, &stats); err != nil {
+		return stats, err
+	}
+
+	burndownTasks := scope.BurndownTasks
+	if burndownTasks == nil {
+		burndownTasks = scope.Tasks
+	}
+
+	burndown, err := Burndown(ctx, burndownTasks, scope.BurndownFrom, scope.BurndownTo)
+	if err != nil {
+		return stats, err
+	}
+	stats.Burndown = burndown
+
+	return stats, nil
+}
+
+func taskCounts(ctx context.Context, scope StatsScope, now time.Time, stats *TaskStats) error {
+	type count struct {
+		N int64 `bson:"n"`
+	}
+
+	since := now.AddDate(0, 0, -7*scope.Weeks)
+	notDone := bson.M{"$nin": scope.DoneStatuses}
+
+	pipeline := bson.A{
+		bson.M{"$match": scope.Tasks},
+		bson.M{"$facet": bson.M{
+			"total":     bson.A{bson.M{"$count": "n"}},
+			"completed": bson.A{bson.M{"$match": bson.M{"status": bson.M{"$in": scope.DoneStatuses}}}, bson.M{"$count": "n"}},
+			"overdue":   bson.A{bson.M{"$match": bson.M{"dueDate": bson.M{"$lt": now}, "status": notDone}}, bson.M{"$count": "n"}},
+			"byStatus": bson.A{
+				bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+			"byAssignee": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":   "$assigned",
+					"total": bson.M{"$sum": 1},
+					"open":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$status", scope.DoneStatuses}}, 0, 1}}},
+				}},
+				bson.M{"$lookup": bson.M{"from": "users", "localField": "_id", "foreignField": "_id", "as": "user"}},
+				bson.M{"$project": bson.M{
+					"_id":    0,
+					"userId": "$_id",
+					"name":   bson.M{"$ifNull": bson.A{bson.M{"$first": "$user.fullname"}, ""}},
+					"total":  1,
+					"open":   1,
+				}},
+				bson.M{"$sort": bson.D{{Key: "open", Value: -1}, {Key: "total", Value: -1}}},
+			},
+			"throughput": bson.A{
+				bson.M{"$match": bson.M{"completedAt": bson.M{"$gte": since}}},
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"$dateTrunc": bson.M{"date": "$completedAt", "unit": "week", "startOfWeek": "monday"}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+				bson.M{"$project": bson.M{"_id": 0, "week": "$_id", "count": 1}},
+			},
+		}},
+	}
+
+	cursor, err := database.DB.Collection("tasks").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var result []struct {
+		Total      []count         `bson:"total"`
+		Completed  []count         `bson:"completed"`
+		Overdue    []count         `bson:"overdue"`
+		ByStatus   []StatusCount   `bson:"byStatus"`
+		ByAssignee []AssigneeCount `bson:"byAssignee"`
+		Throughput []WeekCount     `bson:"throughput"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return err
+	}
+
+	stats.ByStatus = []StatusCount{}
+	stats.ByAssignee = []AssigneeCount{}
+	stats.Throughput = []WeekCount{}
+	if len(result) == 0 {
+		return nil
+	}
+
+	facets := result[0]
+	// $count emits nothing at all when no documents match.
+	if len(facets.Total) > 0 {
+		stats.Total = facets.Total[0].N
+	}
+	if len(facets.Completed) > 0 {
+		stats.Completed = facets.Completed[0].N
+	}
+	if len(facets.Overdue) > 0 {
+		stats.Overdue = facets.Overdue[0].N
+	}
+	if facets.ByStatus != nil {
+		stats.ByStatus = facets.ByStatus
+	}
+	if facets.ByAssignee != nil {
+		stats.ByAssignee = facets.ByAssignee
+	}
+	if facets.Throughput != nil {
+		stats.Throughput = facets.Throughput
+	}
+
+	return nil
+}
+
+// cycleTime averages, per task, the time from its first status change to the
+// last time it was moved into a done column.
+func cycleTime(ctx context.Context, scope StatsScope, stats *TaskStats) error {
+	match := bson.M{"toStatus": bson.M{"$exists": true}}
+	for key, value := range scope.Activity {
+		match[key] = value
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{
+			"_id":       "$taskID",
+			"started":   bson.M{"$min": "$timestamp"},
+			"completed": bson.M{"$max": bson.M{"$cond": bson.A{"$completed", "$timestamp", nil}}},
+		}},
+		bson.M{"$match": bson.M{"completed": bson.M{"$ne": nil}}},
+		bson.M{"$group": bson.M{
+			"_id":     nil,
+			"minutes": bson.M{"$avg": bson.M{"$dateDiff": bson.M{"startDate": "$started", "endDate": "$completed", "unit": "minute"}}},
+			"samples": bson.M{"$sum": 1},
+		}},
+	}
+
+	cursor, err := database.DB.Collection("activity-log").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var result []struct {
+		Minutes float64 `bson:"minutes"`
+		Samples int64   `bson:"samples"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return err
+	}
+
+	if len(result) > 0 {
+		hours := result[0].Minutes / 60
+		stats.AvgCycleTimeHours = &hours
+		stats.CycleTimeSamples = result[0].Samples
+	}
+
+	return nil
+}
+
+// Burndown returns, for each day from from to to, how many matching tasks
+// existed by the end of that day and how many of them were still open.
+func Burndown(ctx context.Context, filter bson.M, from, to time.Time) ([]BurndownPoint, error) {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(to.Sub(from).Hours()/24) + 1
+
+	points := []BurndownPoint{}
+	if days < 1 {
+		return points, nil
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$group": bson.M{
+			"_id":   nil,
+			"tasks": bson.M{"$push": bson.M{"createdAt": "$createdAt", "completedAt": "$completedAt"}},
+		}},
+		bson.M{"$project": bson.M{
+			"_id": 0,
+			"points": bson.M{"$map": bson.M{
+				"input": bson.M{"$range": bson.A{0, days}},
+				"as":    "day",
+				"in": bson.M{"$let": bson.M{
+					"vars": bson.M{
+						"end": bson.M{"$dateAdd": bson.M{"startDate": from, "unit": "day", "amount": bson.M{"$add": bson.A{"$$day", 1}}}},
+					},
+					"in": bson.M{
+						"date": bson.M{"$dateAdd": bson.M{"startDate": from, "unit": "day", "amount": "$$day"}},
+						"scope": bson.M{"$size": bson.M{"$filter": bson.M{
+							"input": "$tasks",
+							"as":    "task",
+							"cond":  bson.M{"$lt": bson.A{"$$task.createdAt", "$$end"}},
+						}}},
+						"remaining": bson.M{"$size": bson.M{"$filter": bson.M{
+							"input": "$tasks",
+							"as":    "task",
+							"cond": bson.M{"$and": bson.A{
+								bson.M{"$lt": bson.A{"$$task.createdAt", "$$end"}},
+								bson.M{"$or": bson.A{
+									bson.M{"$not": bson.A{"$$task.completedAt"}},
+									bson.M{"$gte": bson.A{"$$task.completedAt", "$$end"}},
+								}},
+							}},
+						}}},
+					},
+				}},
+			}},
+		}},
+		bson.M{"$unwind": "$points"},
+		bson.M{"$replaceRoot": bson.M{"newRoot": "$points"}},
+	}
+
+	cursor, err := database.DB.Collection("tasks").Aggregate(ctx, pipeline)
+	if err != nil {
+		return points, err
+	}
+
+	if err = cursor.All(ctx, &points); err != nil {
+		return points, err
+	}
+
+	return points, nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/metrics"
+	"github.com/Loboo34/collab-api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NextPosition returns a rank that places a task at the bottom of a status column.
+func NextPosition(ctx context.Context, projectID primitive.ObjectID, status string) (string, error) {
+	collection := database.DB.Collection("tasks")
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}})
+
+	var last models.Task
+	err := collection.FindOne(ctx, bson.M{"projectId": projectID, "status": status}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return "", err
+	}
+
+	return RankBetween(last.Position, "")
+}
+
+// PositionNear returns a position for task dropped into status after the task
+// prevID and before nextID. When only one neighbour is given, the other is
+// the task actually beside it in the column. If the column has run out of
+// room there, or holds equal positions, it is rebalanced and tried again.
+func PositionNear(ctx context.Context, task models.Task, status string, prevID, nextID primitive.ObjectID) (string, error) {
+	for try := 0; ; try++ {
+		prev, next, err := neighbourPositions(ctx, task, status, prevID, nextID)
+		if err != nil {
+			return "", err
+		}
+
+		position, err := RankBetween(prev, next)
+		if err == nil || try > 0 {
+			return position, err
+		}
+		if err := RebalanceColumn(ctx, task.ProjectId, status, task.ID); err != nil {
+			return "", err
+		}
+	}
+}
+
+func neighbourPositions(ctx context.Context, task models.Task, status string, prevID, nextID primitive.ObjectID) (string, string, error) {
+	collection := database.DB.Collection("tasks")
+	column := bson.M{"projectId": task.ProjectId, "status": status, "_id": bson.M{"$ne": task.ID}}
+
+	position := func(id primitive.ObjectID) (string, error) {
+		var neighbour models.Task
+		err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&neighbour)
+		return neighbour.Position, err
+	}
+	// beside returns the position next to position in the column, or "" at
+	// its end.
+	beside := func(position string, after bool) (string, error) {
+		filter := bson.M{"position": bson.M{"$lt": position}}
+		sort := bson.D{{Key: "position", Value: -1}, {Key: "_id", Value: -1}}
+		if after {
+			filter = bson.M{"position": bson.M{"$gt": position}}
+			sort = bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}}
+		}
+		for key, value := range column {
+			filter[key] = value
+		}
+
+		var neighbour models.Task
+		err := collection.FindOne(ctx, filter, options.FindOne().SetSort(sort)).Decode(&neighbour)
+		if err == mongo.ErrNoDocuments {
+			return "", nil
+		}
+		return neighbour.Position, err
+	}
+
+	var prev, next string
+	var err error
+	if !prevID.IsZero() {
+		if prev, err = position(prevID); err != nil {
+			return "", "", err
+		}
+	}
+	if !nextID.IsZero() {
+		if next, err = position(nextID); err != nil {
+			return "", "", err
+		}
+	}
+
+	switch {
+	case !prevID.IsZero() && nextID.IsZero():
+		next, err = beside(prev, true)
+	case prevID.IsZero() && !nextID.IsZero():
+		prev, err = beside(next, false)
+	}
+	return prev, next, err
+}
+
+// RebalanceColumn rewrites the positions of a status column, apart from the
+// task being moved, evenly spaced in their current order.
+func RebalanceColumn(ctx context.Context, projectID primitive.ObjectID, status string, moving primitive.ObjectID) error {
+	collection := database.DB.Collection("tasks")
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := collection.Find(ctx, bson.M{"projectId": projectID, "status": status, "_id": bson.M{"$ne": moving}}, opts)
+	if err != nil {
+		return err
+	}
+	var tasks []models.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ranks := EvenRanks(len(tasks))
+	updates := make([]mongo.WriteModel, len(tasks))
+	for i, task := range tasks {
+		updates[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": task.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"position": ranks[i]}})
+	}
+	_, err = collection.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// CreateTask places a new task at the bottom of its status column, saves it and
+// links it to its project.
+func CreateTask(ctx context.Context, task *models.Task) error {
+	if task.ID.IsZero() {
+		task.ID = primitive.NewObjectID()
+	}
+	if task.Status == "" {
+		task.Status = "pending"
+	}
+
+	position, err := NextPosition(ctx, task.ProjectId, task.Status)
+	if err != nil {
+		return err
+	}
+	task.Position = position
+
+	_, err = database.DB.Collection("tasks").InsertOne(ctx, task)
+	if err != nil {
+		return err
+	}
+	metrics.TasksCreated.Inc()
+
+	_, err = database.DB.Collection("projects").UpdateOne(ctx, bson.M{"_id": task.ProjectId}, bson.M{"$addToSet": bson.M{"tasks": task.ID}})
+	return err
+}
+
+// StatusUpdate builds the update that moves a task into status at position,
+// keeping completedAt in step with the project's done column. It also reports
+// whether the task ends up done.
+func StatusUpdate(project models.Project, status, position string, now time.Time) (bson.M, bool) {
+	set := bson.M{"status": status, "position": position}
+
+	if status == DoneStatus(project) {
+		set["completedAt"] = now
+		return bson.M{"$set": set}, true
+	}
+
+	return bson.M{"$set": set, "$unset": bson.M{"completedAt": ""}}, false
+}
+
+const maxTitleLength = 200
+
+// ValidateTask checks the fields a client or integration supplies for a new
+// task and normalizes them: the title is trimmed, labels are trimmed and
+// de-duplicated, and the priority's rank is filled in.
+func ValidateTask(task *models.Task) error {
+	task.Title = strings.TrimSpace(task.Title)
+	if task.Title == "" {
+		return errors.New("Title is required")
+	}
+	if len([]rune(task.Title)) > maxTitleLength {
+		return errors.New("Title must be at most 200 characters")
+	}
+
+	if task.Priority != "" && models.TaskPriorities[task.Priority] == 0 {
+		return errors.New("Invalid priority. Must be: low, medium, high or urgent")
+	}
+	task.PriorityRank = models.TaskPriorities[task.Priority]
+
+	var labels []string
+	seen := map[string]bool{}
+	for _, label := range task.Labels {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	task.Labels = labels
+
+	return nil
+}
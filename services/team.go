@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+)
+
+var (
+	ErrTeamNotFound   = errors.New("Team not found")
+	ErrNotMember      = errors.New("Member not found")
+	ErrInviteNotFound = errors.New("Invite not found")
+)
+
+// TeamMembers returns a team's memberships, oldest first.
+func TeamMembers(ctx context.Context, teamID primitive.ObjectID) ([]models.TeamMember, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "joinedat", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := database.DB.Collection("team-members").Find(ctx, bson.M{"teamId": teamID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []models.TeamMember
+	err = cursor.All(ctx, &members)
+	return members, err
+}
+
+// SetMemberRole changes a member's role in a team.
+func SetMemberRole(ctx context.Context, teamID primitive.ObjectID, userID, role string) error {
+	result, err := database.DB.Collection("team-members").UpdateOne(ctx,
+		bson.M{"teamId": teamID, "user": userID},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// RenewInvite gives a pending invite a new token and a fresh expiry, so it
+// can be sent again. The old link stops working.
+func RenewInvite(ctx context.Context, inviteID primitive.ObjectID) (models.Invite, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return models.Invite{}, err
+	}
+
+	var invite models.Invite
+	err := database.DB.Collection("invites").FindOneAndUpdate(ctx,
+		bson.M{"_id": inviteID, "status": "pending"},
+		bson.M{"$set": bson.M{"token": hex.EncodeToString(tokenBytes), "expiresAt": time.Now().Add(models.InviteTTL)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&invite)
+	if err == mongo.ErrNoDocuments {
+		return invite, ErrInviteNotFound
+	}
+	return invite, err
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+)
+
+// APITokenPrefix marks API tokens so CheckAuth can tell them from JWTs.
+const APITokenPrefix = "cat_"
+
+// lastUsedResolution limits how often lastUsedAt is written for a busy token.
+const lastUsedResolution = time.Minute
+
+var ErrInvalidAPIToken = errors.New("Invalid API token")
+
+// IsAPIToken reports whether a bearer credential is an API token.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken stores a new token for userID and returns the plaintext
+// token, which is not kept anywhere and can't be shown again.
+func CreateAPIToken(ctx context.Context, token *models.APIToken) (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	plain := APITokenPrefix + hex.EncodeToString(secret)
+
+	token.ID = primitive.NewObjectID()
+	token.Hash = hashAPIToken(plain)
+	token.Prefix = plain[:len(APITokenPrefix)+6]
+	token.CreatedAt = time.Now()
+
+	if _, err := database.DB.Collection("api-tokens").InsertOne(ctx, token); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// ResolveAPIToken finds the live token matching plain and records that it
+// was used.
+func ResolveAPIToken(ctx context.Context, plain string, now time.Time) (models.APIToken, error) {
+	var token models.APIToken
+
+	collection := database.DB.Collection("api-tokens")
+	err := collection.FindOne(ctx, bson.M{"hash": hashAPIToken(plain), "revokedAt": bson.M{"$exists": false}}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return token, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return token, err
+	}
+
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		return token, ErrInvalidAPIToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": token.ID}, bson.M{"$set": bson.M{"lastUsedAt": now}})
+		if err != nil {
+			return token, err
+		}
+	}
+
+	return token, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/models"
+)
+
+var (
+	ErrEmailTaken   = errors.New("Email already registered")
+	ErrUserNotFound = errors.New("User not found")
+)
+
+// CreateUser saves a new user. The password must already be hashed.
+func CreateUser(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if user.Teams == nil {
+		user.Teams = []primitive.ObjectID{}
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+
+	_, err := database.DB.Collection("users").InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// FindUserByEmail returns ErrUserNotFound when no user has the email.
+func FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := database.DB.Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+// SetPassword replaces a user's password hash and lifts any login lockout
+// on their account.
+func SetPassword(ctx context.Context, user models.User, hash string) error {
+	result, err := database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"password": hash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return ClearLoginFailures(ctx, AccountLoginKey(user.Email))
+}
@@ -2,21 +2,29 @@ package utils
 
 import (
 	"context"
+	"sync"
 	"time"
 
+	"github.com/Loboo34/collab-api/metrics"
 	"github.com/Loboo34/collab-api/models"
 	"github.com/Loboo34/collab-api/services"
-)
+	"github.com/Loboo34/collab-api/tracing"
 
-func Log( userID, teamID, projectID, taskID, action, message string) {
-	
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// pendingLogs tracks background activity log writes so shutdown can wait
+// for them before disconnecting from Mongo.
+var pendingLogs sync.WaitGroup
 
+// WaitForActivityLogs waits until every activity log write has finished.
+func WaitForActivityLogs(ctx context.Context) error {
+	return WaitContext(ctx, &pendingLogs)
+}
 
-	go func () {
-		defer cancel()
-		err := services.CreateLog(ctx, models.ActivityLog{
+func Log(ctx context.Context, userID, teamID, projectID, taskID, action, message string) {
+	LogActivity(ctx, models.ActivityLog{
 		UserID:    userID,
 		TeamID:    teamID,
 		ProjectID: projectID,
@@ -24,9 +32,42 @@ func Log( userID, teamID, projectID, taskID, action, message string) {
 		Action:    action,
 		Message:   message,
 	})
-	if err != nil {
-		Logger.Warn("Failed to Log Activity")
-	}
-	
+}
+
+// LogActivity records a fully populated entry, for callers that need the
+// structured fields Log doesn't take. The write happens in the background;
+// it keeps ctx's trace and logger but not its cancellation, so it finishes
+// after the request that caused it has returned.
+func LogActivity(ctx context.Context, log models.ActivityLog) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	ctx, span := tracing.Tracer().Start(ctx, "activity_log.write", trace.WithAttributes(attribute.String("action", log.Action)))
+
+	metrics.ActivityLogBacklog.Inc()
+	pendingLogs.Add(1)
+	go func() {
+		defer pendingLogs.Done()
+		defer metrics.ActivityLogBacklog.Dec()
+		defer cancel()
+		defer span.End()
+		if err := services.CreateLog(ctx, log); err != nil {
+			span.RecordError(err)
+			LoggerFrom(ctx).Warn("Failed to Log Activity")
+		}
 	}()
 }
+
+// LogStatusChange records a task moving between columns. The from/to fields
+// and the completed flag are what the stats endpoints aggregate over.
+func LogStatusChange(ctx context.Context, userID string, task models.Task, from, to string, completed bool) {
+	LogActivity(ctx, models.ActivityLog{
+		UserID:     userID,
+		TeamID:     task.TeamId.Hex(),
+		ProjectID:  task.ProjectId.Hex(),
+		TaskID:     task.ID.Hex(),
+		Action:     "Update status",
+		Message:    userID + " moved '" + task.Title + "' from " + from + " to " + to,
+		FromStatus: from,
+		ToStatus:   to,
+		Completed:  completed,
+	})
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"net/smtp"
+	"strconv"
+
+	"github.com/Loboo34/collab-api/config"
+)
+
+var smtpSettings config.SMTP
+
+// InitEmail sets the SMTP account emails are sent from.
+func InitEmail(settings config.SMTP) {
+	smtpSettings = settings
+}
+
+// SendEmail sends a plain-text email from the configured SMTP account.
+func SendEmail(toEmail, subject, body string) error {
+	from := smtpSettings.Email
+	smtpHost := smtpSettings.Host
+
+	message := []byte("To: " + toEmail + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body)
+
+	auth := smtp.PlainAuth("", from, smtpSettings.Password.Value(), smtpHost)
+	return smtp.SendMail(smtpHost+":"+strconv.Itoa(smtpSettings.Port), auth, from, []string{toEmail}, message)
+}
@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"context"
 	"errors"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -44,3 +47,49 @@ func GetUserRole(r *http.Request) (string, error) {
 	}
 	return role, nil
 }
+
+var trustProxy bool
+
+// SetTrustProxy tells ClientIP whether the API runs behind a proxy that sets
+// X-Forwarded-For.
+func SetTrustProxy(trust bool) {
+	trustProxy = trust
+}
+
+// ClientIP is the address the request came from. X-Forwarded-For is only
+// trusted when the API runs behind a proxy that sets it; otherwise clients
+// could pick their own address. Even then only the right-most entry is used:
+// that is the one the proxy appended, and anything left of it was sent by
+// the client.
+func ClientIP(r *http.Request) string {
+	if trustProxy {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			forwarded := values[len(values)-1]
+			if ip := strings.TrimSpace(forwarded[strings.LastIndex(forwarded, ",")+1:]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// WaitContext waits for wg, giving up with ctx's error when ctx is done first.
+func WaitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
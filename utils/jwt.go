@@ -2,7 +2,6 @@ package utils
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,8 +10,7 @@ import (
 
 var jwtKey []byte
 
-func InitJWT() error {
-	secret := os.Getenv("JWT_SECRET")
+func InitJWT(secret string) error {
 	if secret == "" {
 		return errors.New("JWT_SECRET not set")
 	}
@@ -46,7 +44,58 @@ func GenerateJWT(userID, email, role string) (string, error) {
 	return token.SignedString(jwtKey)
 }
 
+// Purposes of intermediate tokens issued during login. They only work on
+// the endpoints that finish logging in, never as a session token.
+const (
+	PurposeMFA       = "mfa"        // password checked, TOTP code still needed
+	PurposeMFAEnroll = "mfa-enroll" // a team requires 2FA the user hasn't set up
+)
+
+// GeneratePurposeJWT issues a short-lived token for one step of logging in.
+func GeneratePurposeJWT(userID, purpose string, ttl time.Duration) (string, error) {
+	if len(jwtKey) == 0 {
+		return "", errors.New("JWT key not initialized")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":      userID,
+		"purpose": purpose,
+		"exp":     time.Now().Add(ttl).Unix(),
+	})
+
+	return token.SignedString(jwtKey)
+}
+
+// ValidatePurposeJWT checks a token from GeneratePurposeJWT and returns the
+// user ID it was issued for.
+func ValidatePurposeJWT(tokenString, purpose string) (string, error) {
+	claims, err := parseJWT(tokenString)
+	if err != nil {
+		return "", err
+	}
+	if claims["purpose"] != purpose {
+		return "", errors.New("Invalid token purpose")
+	}
+	userID, ok := claims["id"].(string)
+	if !ok || userID == "" {
+		return "", errors.New("Invalid token")
+	}
+	return userID, nil
+}
+
+// ValidateJWT checks a session token. Intermediate login tokens are refused.
 func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
+	claims, err := parseJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims["purpose"]; ok {
+		return nil, errors.New("Invalid token purpose")
+	}
+	return claims, nil
+}
+
+func parseJWT(tokenString string) (jwt.MapClaims, error) {
 	if len(jwtKey) == 0 {
 		return nil, errors.New("JWT key not initialized")
 	}
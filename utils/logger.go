@@ -1,20 +1,48 @@
 package utils
 
-import(
+import (
+	"context"
 	"log"
+	"net/http"
+
 	"go.uber.org/zap"
 )
 
 var Logger *zap.Logger
 
-func InitLogger(){
+func InitLogger() {
 	var err error
 
 	Logger, err = zap.NewProduction()
-	if err != nil{
+	if err != nil {
 		log.Fatalf("Failed to initialize logger with %v", err)
-		panic(err)
 	}
+}
+
+// SyncLogger flushes buffered log entries; main defers it on shutdown.
+func SyncLogger() {
+	if Logger != nil {
+		_ = Logger.Sync()
+	}
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFrom returns the logger carried by ctx, with the request ID and
+// other request fields, or the global Logger outside a request.
+func LoggerFrom(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return Logger
+}
 
-	defer Logger.Sync()
-}
\ No newline at end of file
+// RequestLogger is LoggerFrom for the request's context.
+func RequestLogger(r *http.Request) *zap.Logger {
+	return LoggerFrom(r.Context())
+}
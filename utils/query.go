@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// ListSpec describes which query params a list endpoint accepts. Each map goes
+// from the param name clients use to the bson field it applies to.
+type ListSpec struct {
+	Sort        map[string]string // ?sort=-createdAt,title
+	DefaultSort string
+	Equals      map[string]string // ?status=pending,done matches any of the values
+	ObjectIDs   map[string]string // ?assignee=<id>,<id> matches any of the ids
+	Arrays      map[string]string // ?labels=bug,ui matches documents carrying all labels
+	Ranges      map[string]string // ?createdFrom=&createdTo= (RFC 3339 or YYYY-MM-DD)
+}
+
+// ListQuery is a parsed list request, ready to run with FindPage.
+type ListQuery struct {
+	Filter bson.M
+	Sort   bson.D
+	Limit  int64
+	Offset int64
+	Cursor string
+}
+
+// ParseListQuery reads limit, offset or cursor, sort and the filters allowed by
+// spec from the request, merged on top of the endpoint's base filter.
+func ParseListQuery(r *http.Request, base bson.M, spec ListSpec) (ListQuery, error) {
+	values := r.URL.Query()
+	query := ListQuery{Filter: bson.M{}, Limit: defaultPageSize}
+
+	for key, value := range base {
+		query.Filter[key] = value
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || n < 1 || n > maxPageSize {
+			return query, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		query.Limit = n
+	}
+
+	query.Cursor = values.Get("cursor")
+	if offset := values.Get("offset"); offset != "" {
+		if query.Cursor != "" {
+			return query, errors.New("use either offset or cursor, not both")
+		}
+		n, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || n < 0 {
+			return query, errors.New("offset must be a positive number")
+		}
+		query.Offset = n
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = spec.DefaultSort
+	}
+	for _, field := range strings.Split(sortParam, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		direction := 1
+		if strings.HasPrefix(field, "-") {
+			direction = -1
+			field = field[1:]
+		}
+
+		key, ok := spec.Sort[field]
+		if !ok {
+			return query, errors.New("cannot sort by " + field)
+		}
+		query.Sort = append(query.Sort, bson.E{Key: key, Value: direction})
+	}
+
+	// _id breaks ties so pages never overlap and cursors stay unambiguous.
+	if len(query.Sort) == 0 || query.Sort[len(query.Sort)-1].Key != "_id" {
+		query.Sort = append(query.Sort, bson.E{Key: "_id", Value: 1})
+	}
+
+	for param, key := range spec.Equals {
+		if value := values.Get(param); value != "" {
+			query.Filter[key] = bson.M{"$in": strings.Split(value, ",")}
+		}
+	}
+
+	for param, key := range spec.ObjectIDs {
+		value := values.Get(param)
+		if value == "" {
+			continue
+		}
+
+		var ids []primitive.ObjectID
+		for _, hex := range strings.Split(value, ",") {
+			id, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				return query, errors.New("invalid id in " + param)
+			}
+			ids = append(ids, id)
+		}
+		query.Filter[key] = bson.M{"$in": ids}
+	}
+
+	for param, key := range spec.Arrays {
+		if value := values.Get(param); value != "" {
+			query.Filter[key] = bson.M{"$all": strings.Split(value, ",")}
+		}
+	}
+
+	for param, key := range spec.Ranges {
+		bounds := bson.M{}
+		for suffix, operator := range map[string]string{"From": "$gte", "To": "$lte"} {
+			value := values.Get(param + suffix)
+			if value == "" {
+				continue
+			}
+
+			t, err := parseQueryTime(value, suffix == "To")
+			if err != nil {
+				return query, errors.New("invalid date in " + param + suffix)
+			}
+			bounds[operator] = t
+		}
+		if len(bounds) > 0 {
+			query.Filter[key] = bounds
+		}
+	}
+
+	return query, nil
+}
+
+// parseQueryTime accepts RFC 3339 timestamps or plain dates; a plain date used
+// as an upper bound covers the whole day.
+func parseQueryTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+// Page is one page of results plus what is needed to set paging headers.
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string
+}
+
+// FindPage runs a list query. Cursor paging is keyset based: the cursor holds
+// the sort values of the last item, so later pages stay stable while
+// documents are added or removed.
+func FindPage[T any](ctx context.Context, collection *mongo.Collection, query ListQuery) (Page[T], error) {
+	page := Page[T]{Items: []T{}}
+
+	total, err := collection.CountDocuments(ctx, query.Filter)
+	if err != nil {
+		return page, err
+	}
+	page.Total = total
+
+	filter := query.Filter
+	if query.Cursor != "" {
+		after, err := cursorFilter(query.Cursor, query.Sort)
+		if err != nil {
+			return page, err
+		}
+		filter = bson.M{"$and": bson.A{query.Filter, after}}
+	}
+
+	opts := options.Find().SetSort(query.Sort).SetLimit(query.Limit)
+	if query.Offset > 0 {
+		opts.SetSkip(query.Offset)
+	}
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return page, err
+	}
+	defer cursor.Close(ctx)
+
+	var last bson.Raw
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, item)
+		last = cursor.Current
+	}
+	if err := cursor.Err(); err != nil {
+		return page, err
+	}
+
+	if int64(len(page.Items)) == query.Limit && last != nil {
+		page.NextCursor, err = encodeCursor(last, query.Sort)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	return page, nil
+}
+
+func encodeCursor(doc bson.Raw, sort bson.D) (string, error) {
+	values := bson.A{}
+	for _, field := range sort {
+		value, err := doc.LookupErr(strings.Split(field.Key, ".")...)
+		if err != nil {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, value)
+	}
+
+	data, err := bson.Marshal(bson.M{"v": values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// cursorFilter matches documents sorting after the cursor position:
+// (f1 > v1) or (f1 = v1 and f2 > v2) or ... with the comparison flipped for
+// descending fields. A missing field is encoded as null, which Mongo sorts
+// before every other value, so comparisons involving null are spelled out.
+func cursorFilter(cursor string, sort bson.D) (bson.M, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var decoded struct {
+		V []interface{} `bson:"v"`
+	}
+	if err := bson.Unmarshal(data, &decoded); err != nil || len(decoded.V) != len(sort) {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var clauses bson.A
+	for i, field := range sort {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[sort[j].Key] = decoded.V[j]
+		}
+
+		value := decoded.V[i]
+		switch {
+		case field.Value == -1 && value == nil:
+			// Nothing sorts below null, so only the later fields can advance.
+			continue
+		case field.Value == -1:
+			clause["$or"] = bson.A{bson.M{field.Key: bson.M{"$lt": value}}, bson.M{field.Key: nil}}
+		case value == nil:
+			clause[field.Key] = bson.M{"$ne": nil}
+		default:
+			clause[field.Key] = bson.M{"$gt": value}
+		}
+		clauses = append(clauses, clause)
+	}
+
+	return bson.M{"$or": clauses}, nil
+}
+
+// SetPageHeaders sets X-Total-Count and a Link header with first, prev and
+// next relations built from the current request URL.
+func SetPageHeaders[T any](w http.ResponseWriter, r *http.Request, query ListQuery, page Page[T]) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+
+	link := func(rel string, change func(url.Values)) string {
+		values := r.URL.Query()
+		values.Del("cursor")
+		values.Del("offset")
+		change(values)
+
+		u := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+		return "<" + u.String() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{link("first", func(url.Values) {})}
+
+	if query.Cursor == "" && query.Offset > 0 {
+		prev := query.Offset - query.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link("prev", func(v url.Values) { v.Set("offset", strconv.FormatInt(prev, 10)) }))
+	}
+
+	switch {
+	case query.Cursor == "" && query.Offset > 0 && query.Offset+query.Limit < page.Total:
+		links = append(links, link("next", func(v url.Values) { v.Set("offset", strconv.FormatInt(query.Offset+query.Limit, 10)) }))
+	case query.Offset == 0 && page.NextCursor != "":
+		links = append(links, link("next", func(v url.Values) { v.Set("cursor", page.NextCursor) }))
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
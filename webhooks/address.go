@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for webhook hosts that resolve to loopback,
+// link-local, private or otherwise internal addresses. Delivering there
+// would let team admins probe the network the API runs in.
+var ErrPrivateAddress = errors.New("webhook URLs must point to a public address")
+
+// newClient builds the delivery client. control runs on every dial with the
+// resolved address, so it also covers DNS answers that change after the
+// webhook was registered. Redirects are not followed, since their target
+// hasn't been checked, and proxies are bypassed for the same reason.
+func newClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second, Control: control}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkDial is the dialer control that refuses non-public addresses.
+func checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !publicAddr(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// publicAddr reports whether ip is routable on the internet.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which IsPrivate leaves out.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// ValidateURL checks a webhook URL before it is stored: it must be absolute
+// http or https and its host must resolve to public addresses only. Deliveries
+// check again when they dial.
+func ValidateURL(ctx context.Context, raw string) (*url.URL, error) {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errors.New("URL must be an absolute http or https URL")
+	}
+
+	host := target.Hostname()
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(ip) {
+			return nil, ErrPrivateAddress
+		}
+		return target, nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return nil, ErrPrivateAddress
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, errors.New("URL host " + host + " does not resolve")
+	}
+	for _, ip := range ips {
+		if !publicAddr(ip) {
+			return nil, ErrPrivateAddress
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,154 @@
+// Package webhooks queues domain events for teams' outbound webhooks and
+// delivers them as signed JSON requests.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/Loboo34/collab-api/database"
+	"github.com/Loboo34/collab-api/events"
+	"github.com/Loboo34/collab-api/models"
+	"github.com/Loboo34/collab-api/utils"
+)
+
+// Request headers sent with every delivery. The signature covers the
+// timestamp and the body, "<timestamp>.<body>", so receivers can reject
+// replays of old deliveries.
+const (
+	HeaderEvent     = "X-Collab-Event"
+	HeaderDelivery  = "X-Collab-Delivery"
+	HeaderTimestamp = "X-Collab-Timestamp"
+	HeaderSignature = "X-Collab-Signature"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it is marked failed.
+	MaxAttempts = 8
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+var client = newClient(checkDial)
+
+// Register subscribes the webhook queue to every event.
+func Register() {
+	events.Subscribe(events.All, enqueue)
+}
+
+// enqueue stores one pending delivery per active webhook of the event's team
+// that subscribed to its type. Delivery itself happens in the background job,
+// so the queue survives restarts.
+func enqueue(ctx context.Context, event events.Event) {
+	teamID, err := primitive.ObjectIDFromHex(event.TeamID)
+	if err != nil {
+		return
+	}
+
+	cursor, err := database.DB.Collection("webhooks").Find(ctx, bson.M{"teamId": teamID, "active": true, "events": event.Type})
+	if err != nil {
+		utils.Logger.Warn("Failed to find webhooks", zap.String("event", event.Type), zap.Error(err))
+		return
+	}
+
+	var hooks []models.Webhook
+	if err = cursor.All(ctx, &hooks); err != nil {
+		utils.Logger.Warn("Failed to decode webhooks", zap.String("event", event.Type), zap.Error(err))
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		utils.Logger.Warn("Failed to encode webhook payload", zap.String("event", event.Type), zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	var deliveries []interface{}
+	for _, hook := range hooks {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            primitive.NewObjectID(),
+			WebhookId:     hook.ID,
+			TeamId:        teamID,
+			Event:         event.Type,
+			Payload:       string(payload),
+			Status:        "pending",
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		})
+	}
+
+	if _, err := database.DB.Collection("webhook-deliveries").InsertMany(ctx, deliveries); err != nil {
+		utils.Logger.Warn("Failed to queue webhook deliveries", zap.String("event", event.Type), zap.Error(err))
+	}
+}
+
+// Sign returns the signature header value for a payload sent at timestamp.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts one delivery to the webhook and returns the response status.
+// Any non-2xx status is an error.
+func Send(ctx context.Context, hook models.Webhook, delivery models.WebhookDelivery, now time.Time) (int, error) {
+	payload := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "collab-api-webhooks")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID.Hex())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New("receiver responded with " + resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff is the wait before retrying a delivery that has failed attempts
+// times: 30s, 1m, 2m, ... capped at six hours.
+func Backoff(attempts int) time.Duration {
+	backoff := baseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,196 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Loboo34/collab-api/models"
+)
+
+// allowLoopback lets deliveries reach httptest receivers, which listen on
+// 127.0.0.1, for the rest of the test.
+func allowLoopback(t *testing.T) {
+	saved := client
+	client = newClient(nil)
+	t.Cleanup(func() { client = saved })
+}
+
+func testDelivery() (models.Webhook, models.WebhookDelivery) {
+	hook := models.Webhook{ID: primitive.NewObjectID(), Secret: "s3cret"}
+	delivery := models.WebhookDelivery{
+		ID:        primitive.NewObjectID(),
+		WebhookId: hook.ID,
+		Event:     "task.created",
+		Payload:   `{"type":"task.created"}`,
+	}
+	return hook, delivery
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+	allowLoopback(t)
+	hook, delivery := testDelivery()
+	now := time.Unix(1700000000, 0)
+
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	hook.URL = receiver.URL
+
+	status, err := Send(context.Background(), hook, delivery, now)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v; want 204, nil", status, err)
+	}
+
+	if string(body) != delivery.Payload {
+		t.Errorf("body = %q, want %q", body, delivery.Payload)
+	}
+	if got.Header.Get(HeaderEvent) != "task.created" || got.Header.Get(HeaderDelivery) != delivery.ID.Hex() {
+		t.Errorf("event headers = %q, %q", got.Header.Get(HeaderEvent), got.Header.Get(HeaderDelivery))
+	}
+
+	timestamp, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil || timestamp != now.Unix() {
+		t.Fatalf("timestamp header = %q", got.Header.Get(HeaderTimestamp))
+	}
+	if want := Sign(hook.Secret, timestamp, body); got.Header.Get(HeaderSignature) != want {
+		t.Errorf("signature = %q, want %q", got.Header.Get(HeaderSignature), want)
+	}
+	if Sign("other", timestamp, body) == got.Header.Get(HeaderSignature) {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+func TestSendFailsOnErrorStatus(t *testing.T) {
+	allowLoopback(t)
+	hook, delivery := testDelivery()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+	hook.URL = receiver.URL
+
+	status, err := Send(context.Background(), hook, delivery, time.Now())
+	if err == nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("Send = %d, %v; want 503 and an error", status, err)
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	allowLoopback(t)
+	hook, delivery := testDelivery()
+
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	receiver := httptest.NewServer(mux)
+	defer receiver.Close()
+	hook.URL = receiver.URL + "/hook"
+
+	status, err := Send(context.Background(), hook, delivery, time.Now())
+	if err == nil || status != http.StatusTemporaryRedirect {
+		t.Fatalf("Send = %d, %v; want 307 and an error", status, err)
+	}
+	if followed {
+		t.Error("the redirect was followed")
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	hook, delivery := testDelivery()
+
+	reached := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer receiver.Close()
+	hook.URL = receiver.URL
+
+	_, err := Send(context.Background(), hook, delivery, time.Now())
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("Send error = %v, want ErrPrivateAddress", err)
+	}
+	if reached {
+		t.Error("the loopback receiver was reached")
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"0.0.0.0":              false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"224.0.0.1":            false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:10.0.0.1":      false,
+	}
+	for addr, want := range tests {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://93.184.216.34/hook":        true,
+		"ftp://93.184.216.34/hook":          false,
+		"/hook":                             false,
+		"http://127.0.0.1:8080/hook":        false,
+		"http://[::1]/hook":                 false,
+		"http://169.254.169.254/latest":     false,
+		"http://10.0.0.5/hook":              false,
+		"http://localhost:3000/hook":        false,
+		"http://metrics.localhost:3000/":    false,
+		"http://[::ffff:192.168.0.1]/hook":  false,
+		"https://93.184.216.34:8443/events": true,
+	}
+	for raw, want := range tests {
+		_, err := ValidateURL(context.Background(), raw)
+		if (err == nil) != want {
+			t.Errorf("ValidateURL(%s) = %v, want ok %v", raw, err, want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		20: 6 * time.Hour,
+	}
+	for attempts, want := range tests {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}